@@ -0,0 +1,265 @@
+package proton
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+
+	"github.com/leandroveronezi/proton/internal/tsgen"
+)
+
+// protonBinary installs window.proton.binary, used by the binding stubs to send typed
+// arrays, ArrayBuffers and Blobs to Go and to turn binary results back into Uint8Arrays.
+// Typed arrays travel as {$binary: base64}, a Blob stays in the page and travels as
+// {$blob: index} so Go reads its content only when and as far as it needs it.
+const protonBinary = `(() => {
+	const proton = window.proton = window.proton || {};
+	if (proton.binary) {
+		return;
+	}
+	const toBase64 = (bytes) => {
+		let s = '';
+		for (let i = 0; i < bytes.length; i += 0x8000) {
+			s += String.fromCharCode.apply(null, bytes.subarray(i, i + 0x8000));
+		}
+		return btoa(s);
+	};
+	const fromBase64 = (s) => {
+		const b = atob(s);
+		const bytes = new Uint8Array(b.length);
+		for (let i = 0; i < b.length; i++) {
+			bytes[i] = b.charCodeAt(i);
+		}
+		return bytes;
+	};
+	proton.binary = {
+		toBase64,
+		// A JSON.stringify replacer collecting the Blobs of the arguments in blobs
+		replacer: (blobs) => (key, value) => {
+			if (value instanceof ArrayBuffer) {
+				return {'$binary': toBase64(new Uint8Array(value))};
+			}
+			if (ArrayBuffer.isView(value)) {
+				return {'$binary': toBase64(new Uint8Array(value.buffer, value.byteOffset, value.byteLength))};
+			}
+			if (typeof Blob !== 'undefined' && value instanceof Blob) {
+				blobs.push(value);
+				return {'$blob': blobs.length - 1, size: value.size, type: value.type, name: value.name};
+			}
+			return value;
+		},
+		decode: (value) => {
+			if (value !== null && typeof value === 'object' && typeof value['$binary'] === 'string') {
+				return fromBase64(value['$binary']);
+			}
+			return value;
+		},
+	};
+})();`
+
+// bindingBlob reads a slice of a Blob passed to a binding call, it is called with the binding
+// name, the call sequence number, the index of the blob, the offset and the size to read.
+const bindingBlob = `async function(name, seq, index, offset, size) {
+	const blobs = window[name] && window[name]['blobs'].get(seq);
+	const blob = blobs && blobs[index];
+	if (!blob) {
+		throw new Error('blob is no longer available');
+	}
+	const bytes = new Uint8Array(await blob.slice(offset, offset + size).arrayBuffer());
+	return window.proton.binary.toBase64(bytes);
+}`
+
+// blobChunkSize is the size of the slices of a Blob read at once.
+const blobChunkSize = 1 << 20
+
+var readerType = reflect.TypeOf((*io.Reader)(nil)).Elem()
+
+// blobKey is the context key of the blobFunc of a binding call.
+type blobKey struct{}
+
+// blobFunc reads size bytes from offset of a Blob passed to a binding call.
+type blobFunc func(index int, offset int64, size int) ([]byte, error)
+
+// binaryArgument is an argument sent as {$binary} or {$blob} by a binding stub.
+type binaryArgument struct {
+	Binary *[]byte `json:"$binary"`
+	Blob   *int    `json:"$blob"`
+	Size   int64   `json:"size"`
+}
+
+// blobReader reads a Blob of the page as the Go function consumes it.
+type blobReader struct {
+	read   blobFunc
+	index  int
+	size   int64
+	offset int64
+	buf    []byte
+}
+
+func (_this *blobReader) Read(p []byte) (int, error) {
+
+	if len(_this.buf) == 0 {
+
+		if _this.offset >= _this.size {
+			return 0, io.EOF
+		}
+
+		size := blobChunkSize
+		if left := _this.size - _this.offset; left < int64(size) {
+			size = int(left)
+		}
+
+		chunk, err := _this.read(_this.index, _this.offset, size)
+		if err != nil {
+			return 0, err
+		}
+		if len(chunk) == 0 {
+			// The blob is smaller than announced
+			return 0, io.ErrUnexpectedEOF
+		}
+
+		_this.offset += int64(len(chunk))
+		_this.buf = chunk
+	}
+
+	n := copy(p, _this.buf)
+	_this.buf = _this.buf[n:]
+
+	return n, nil
+}
+
+// binaryReader returns the io.Reader passed for a JS argument: the bytes of a typed
+// array, the content of a Blob, or the text of a string.
+func binaryReader(ctx context.Context, raw json.RawMessage) (io.Reader, error) {
+
+	if bytes.Equal(raw, []byte("null")) {
+		return nil, nil
+	}
+
+	var s string
+	if json.Unmarshal(raw, &s) == nil {
+		return strings.NewReader(s), nil
+	}
+
+	arg := binaryArgument{}
+	if err := json.Unmarshal(raw, &arg); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case arg.Binary != nil:
+		return bytes.NewReader(*arg.Binary), nil
+	case arg.Blob != nil:
+		read, _ := ctx.Value(blobKey{}).(blobFunc)
+		if read == nil {
+			return nil, errors.New("blob can't be read")
+		}
+		return &blobReader{read: read, index: *arg.Blob, size: arg.Size}, nil
+	}
+
+	return nil, errors.New("expected a Uint8Array, an ArrayBuffer or a Blob")
+}
+
+// inlineBinary replaces the typed arrays and Blobs of a JS argument by base64 strings,
+// so they decode into []byte like encoding/json expects.
+func inlineBinary(ctx context.Context, raw json.RawMessage) (json.RawMessage, error) {
+
+	if !bytes.Contains(raw, []byte(`"$binary"`)) && !bytes.Contains(raw, []byte(`"$blob"`)) {
+		return raw, nil
+	}
+
+	d := json.NewDecoder(bytes.NewReader(raw))
+	d.UseNumber()
+
+	var v interface{}
+	if err := d.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	v, err := inlineValue(ctx, v)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
+
+func inlineValue(ctx context.Context, v interface{}) (interface{}, error) {
+
+	switch x := v.(type) {
+	case []interface{}:
+		for i := range x {
+			var err error
+			if x[i], err = inlineValue(ctx, x[i]); err != nil {
+				return nil, err
+			}
+		}
+	case map[string]interface{}:
+		if b, ok := x["$binary"].(string); ok {
+			return b, nil
+		}
+		if _, ok := x["$blob"]; ok {
+			b, _ := json.Marshal(x)
+			r, err := binaryReader(ctx, b)
+			if err != nil {
+				return nil, err
+			}
+			// Encoded as base64 by json.Marshal
+			return io.ReadAll(r)
+		}
+		for k := range x {
+			var err error
+			if x[k], err = inlineValue(ctx, x[k]); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	return v, nil
+}
+
+// isBinary reports whether values of type t are binary data, by the rule their declarations follow.
+func isBinary(t reflect.Type) bool {
+	return tsgen.IsBinary(tsType{t})
+}
+
+// binaryValue wraps binary data and io.Reader results so the page receives them as a Uint8Array.
+func binaryValue(v interface{}) (interface{}, error) {
+
+	if v != nil && isBinary(reflect.TypeOf(v)) {
+		b := reflect.ValueOf(v).Bytes()
+		if b == nil {
+			return nil, nil
+		}
+		return h{"$binary": b}, nil
+	}
+
+	switch b := v.(type) {
+	case io.Reader:
+		if c, ok := b.(io.Closer); ok {
+			defer c.Close()
+		}
+		data, err := io.ReadAll(b)
+		if err != nil {
+			return nil, err
+		}
+		return h{"$binary": data}, nil
+	}
+
+	return v, nil
+}
+
+// marshalResult encodes a result or a streamed item of a bound function.
+func marshalResult(v interface{}) (json.RawMessage, error) {
+
+	v, err := binaryValue(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(v)
+}
@@ -2,16 +2,14 @@ package proton
 
 import (
 	"bufio"
+	"context"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"golang.org/x/net/websocket"
 	"io"
 	"io/ioutil"
 	"log"
 	"os"
 	"os/exec"
-	"reflect"
 	"regexp"
 	"runtime"
 	"strings"
@@ -27,33 +25,73 @@ type result struct {
 	Err   error
 }
 
-type bindingFunc func(args []json.RawMessage) (interface{}, error)
+type pendingCall struct {
+	session string
+	method  string
+	resc    chan result
+}
+
+type outboundFrame struct {
+	id   int
+	data []byte
+}
+
+type bindingFunc func(ctx context.Context, args []json.RawMessage) (interface{}, error)
 
 // Msg is a struct for incoming messages (results and async events)
 type msg struct {
-	ID     int             `json:"id"`
-	Result json.RawMessage `json:"result"`
-	Error  json.RawMessage `json:"error"`
-	Method string          `json:"method"`
-	Params json.RawMessage `json:"params"`
+	ID        int             `json:"id"`
+	Result    json.RawMessage `json:"result"`
+	Error     json.RawMessage `json:"error"`
+	Method    string          `json:"method"`
+	Params    json.RawMessage `json:"params"`
+	SessionID string          `json:"sessionId"`
 }
 
 type Browser struct {
 	config Config
 	done   chan struct{}
 	sync.Mutex
-	cmd      *exec.Cmd
-	ws       *websocket.Conn
-	id       int32
-	target   string
-	session  string
-	window   int
-	pending  map[int]chan result
-	bindings map[string]bindingFunc
+	cmd     *exec.Cmd
+	conn    Transport
+	id      int32
+	page    *Page
+	pages   map[string]*Page
+	pending map[int]pendingCall
+	closed  bool
+
+	outgoing chan outboundFrame
+	stopped  chan struct{}
+
+	events     *eventQueue
+	listeners  map[string][]listener
+	listenerID int
+	// Channels of Subscribe, closed when the event loop ends
+	subscriptions map[*subscription]bool
+
+	middleware []func(next BindingHandler) BindingHandler
+	origins    []string
+}
+
+// sendJSON and receiveJSON talk to the transport directly, before readLoop is started.
+func (_this *Browser) sendJSON(v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return _this.conn.Send(b)
+}
+
+func (_this *Browser) receiveJSON(v interface{}) error {
+	b, err := _this.conn.Receive()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
 }
 
 func (_this *Browser) findTarget() (string, error) {
-	err := websocket.JSON.Send(_this.ws, h{
+	err := _this.sendJSON(h{
 		"id": 0, "method": "Target.setDiscoverTargets", "params": h{"discover": true},
 	})
 	if err != nil {
@@ -61,7 +99,7 @@ func (_this *Browser) findTarget() (string, error) {
 	}
 	for {
 		m := msg{}
-		if err = websocket.JSON.Receive(_this.ws, &m); err != nil {
+		if err = _this.receiveJSON(&m); err != nil {
 			return "", err
 		} else if m.Method == "Target.targetCreated" {
 			target := struct {
@@ -80,19 +118,23 @@ func (_this *Browser) findTarget() (string, error) {
 }
 
 func (_this *Browser) startSession(target string) (string, error) {
-	err := websocket.JSON.Send(_this.ws, h{
-		"id": 1, "method": "Target.attachToTarget", "params": h{"targetId": target},
+	err := _this.sendJSON(h{
+		"id": 1, "method": "Target.attachToTarget", "params": h{"targetId": target, "flatten": true},
 	})
 	if err != nil {
 		return "", err
 	}
 	for {
 		m := msg{}
-		if err = websocket.JSON.Receive(_this.ws, &m); err != nil {
+		if err = _this.receiveJSON(&m); err != nil {
 			return "", err
 		} else if m.ID == 1 {
 			if m.Error != nil {
-				return "", errors.New("Target error: " + string(m.Error))
+				perr := &ProtocolError{Method: "Target.attachToTarget"}
+				if err := json.Unmarshal(m.Error, perr); err != nil {
+					return "", err
+				}
+				return "", perr
 			}
 			session := struct {
 				ID string `json:"sessionId"`
@@ -116,95 +158,84 @@ func (_this *Browser) getWindowForTarget(target string) (windowTargetMessage, er
 }
 
 func (_this *Browser) readLoop() {
+	defer _this.events.close()
+	defer _this.failPending()
+
 	for {
-		m := msg{}
-		if err := websocket.JSON.Receive(_this.ws, &m); err != nil {
+		data, err := _this.conn.Receive()
+		if err != nil {
 			return
 		}
 
-		if m.Method == "Target.receivedMessageFromTarget" {
-			params := struct {
-				SessionID string `json:"sessionId"`
-				Message   string `json:"message"`
-			}{}
-			json.Unmarshal(m.Params, &params)
-			if params.SessionID != _this.session {
+		m := msg{}
+		json.Unmarshal(data, &m)
+
+		// Pending calls are keyed by id, the reply of a call on a page detached meanwhile is still awaited
+		if m.ID != 0 {
+			_this.reply(m.ID, data)
+			continue
+		}
+
+		var page *Page
+		if m.SessionID != "" {
+			_this.Lock()
+			page = _this.pages[m.SessionID]
+			_this.Unlock()
+			if page == nil {
 				continue
 			}
-			res := targetMessage{}
-			json.Unmarshal([]byte(params.Message), &res)
+		}
 
-			if res.ID == 0 && res.Method == "Runtime.consoleAPICalled" || res.Method == "Runtime.exceptionThrown" {
+		if m.Method != "" {
+			_this.emit(m)
+		}
 
-				if _this.config.Debug {
-					log.Println(params.Message)
-				}
+		switch m.Method {
+		case "Runtime.consoleAPICalled", "Runtime.exceptionThrown":
 
-			} else if res.ID == 0 && res.Method == "Runtime.bindingCalled" {
-				payload := struct {
-					Name string            `json:"name"`
-					Seq  int               `json:"seq"`
-					Args []json.RawMessage `json:"args"`
-				}{}
-				json.Unmarshal([]byte(res.Params.Payload), &payload)
-
-				_this.Lock()
-				binding, ok := _this.bindings[res.Params.Name]
-				_this.Unlock()
-				if ok {
-					jsString := func(v interface{}) string { b, _ := json.Marshal(v); return string(b) }
-					go func() {
-						result, error := "", `""`
-						if r, err := binding(payload.Args); err != nil {
-							error = jsString(err.Error())
-						} else if b, err := json.Marshal(r); err != nil {
-							error = jsString(err.Error())
-						} else {
-							result = string(b)
-						}
-						expr := fmt.Sprintf(`
-							if (%[4]s) {
-								window['%[1]s']['errors'].get(%[2]d)(%[4]s);
-							} else {
-								window['%[1]s']['callbacks'].get(%[2]d)(%[3]s);
-							}
-							window['%[1]s']['callbacks'].delete(%[2]d);
-							window['%[1]s']['errors'].delete(%[2]d);
-							`, payload.Name, payload.Seq, result, error)
-						_this.send("Runtime.evaluate", h{"expression": expr, "contextId": res.Params.ID})
-					}()
-				}
-				continue
+			if _this.config.Debug {
+				log.Println(string(data))
 			}
 
-			_this.Lock()
-			resc, ok := _this.pending[res.ID]
-			delete(_this.pending, res.ID)
-			_this.Unlock()
+		case "Runtime.bindingCalled":
 
-			if !ok {
-				continue
+			if page != nil {
+				page.bindingCalled(m.Params)
+			}
+
+		case "Runtime.executionContextCreated":
+
+			if page != nil {
+				page.contextCreated(m.Params)
+			}
+
+		case "Runtime.executionContextDestroyed":
+
+			if page != nil {
+				params := struct {
+					ID int `json:"executionContextId"`
+				}{}
+				json.Unmarshal(m.Params, &params)
+				page.contextDestroyed(params.ID)
+				page.cancelCalls(func(k callKey) bool { return k.context == params.ID })
 			}
 
-			if res.Error.Message != "" {
-				resc <- result{Err: errors.New(res.Error.Message)}
-			} else if res.Result.Exception.Exception.Value != nil {
-				resc <- result{Err: errors.New(string(res.Result.Exception.Exception.Value))}
-			} else if res.Result.Result.Type == "object" && res.Result.Result.Subtype == "error" {
-				resc <- result{Err: errors.New(res.Result.Result.Description)}
-			} else if res.Result.Result.Type != "" {
-				resc <- result{Value: res.Result.Result.Value}
-			} else {
-				res := targetMessageTemplate{}
-				json.Unmarshal([]byte(params.Message), &res)
-				resc <- result{Value: res.Result}
+		case "Runtime.executionContextsCleared":
+
+			if page != nil {
+				page.contextDestroyed(0)
+				page.cancelCalls(func(callKey) bool { return true })
 			}
-		} else if m.Method == "Target.targetDestroyed" {
+
+		case "Target.targetDestroyed", "Target.detachedFromTarget":
 			params := struct {
-				TargetID string `json:"targetId"`
+				TargetID  string `json:"targetId"`
+				SessionID string `json:"sessionId"`
 			}{}
 			json.Unmarshal(m.Params, &params)
-			if params.TargetID == _this.target {
+			// The first page is no different, other pages may still be open.
+			// Once the last one is gone, so is the browser for proton.
+			if _this.detach(params.TargetID, params.SessionID) {
 				_this.kill(true)
 				return
 			}
@@ -212,126 +243,282 @@ func (_this *Browser) readLoop() {
 	}
 }
 
+// reply delivers the result of a command to the caller waiting for it.
+func (_this *Browser) reply(id int, data []byte) {
+
+	_this.Lock()
+	call, ok := _this.pending[id]
+	delete(_this.pending, id)
+	_this.Unlock()
+
+	if !ok {
+		return
+	}
+
+	res := targetMessage{}
+	json.Unmarshal(data, &res)
+
+	if res.Error != nil {
+		res.Error.Method = call.method
+		call.resc <- result{Err: res.Error}
+	} else if res.Result.Exception != nil {
+		call.resc <- result{Err: res.Result.Exception}
+	} else if res.Result.Result.ObjectID != "" {
+		// Returned by reference, the whole remote object is needed to make a RemoteObject.
+		// An Error object is a value like any other then.
+		res := struct {
+			Result struct {
+				Result json.RawMessage `json:"result"`
+			} `json:"result"`
+		}{}
+		json.Unmarshal(data, &res)
+		call.resc <- result{Value: res.Result.Result}
+	} else if res.Result.Result.Type == "object" && res.Result.Result.Subtype == "error" {
+		call.resc <- result{Err: errors.New(res.Result.Result.Description)}
+	} else if res.Result.Result.Type != "" {
+		call.resc <- result{Value: res.Result.Result.Value}
+	} else {
+		res := targetMessageTemplate{}
+		json.Unmarshal(data, &res)
+		call.resc <- result{Value: res.Result}
+	}
+}
+
 func (_this *Browser) send(method string, params h) (json.RawMessage, error) {
+	return _this.sendContext(context.Background(), method, params)
+}
+
+func (_this *Browser) sendContext(ctx context.Context, method string, params h) (json.RawMessage, error) {
+	return _this.call(ctx, "", method, params)
+}
+
+// call sends a command to the given session, or to the browser itself when session is empty.
+func (_this *Browser) call(ctx context.Context, session string, method string, params h) (json.RawMessage, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
 	id := atomic.AddInt32(&_this.id, 1)
-	b, err := json.Marshal(h{"id": int(id), "method": method, "params": params})
+	m := h{"id": int(id), "method": method, "params": params}
+	if session != "" {
+		m["sessionId"] = session
+	}
+	b, err := json.Marshal(m)
 	if err != nil {
 		return nil, err
 	}
-	resc := make(chan result)
+	// Buffered, so readLoop never blocks on a caller that stopped waiting
+	resc := make(chan result, 1)
 	_this.Lock()
-	_this.pending[int(id)] = resc
+	if _this.closed {
+		_this.Unlock()
+		return nil, ErrBrowserClosed
+	}
+	_this.pending[int(id)] = pendingCall{session: session, method: method, resc: resc}
 	_this.Unlock()
 
 	if _this.config.Debug {
 		log.Println(string(b))
 	}
 
-	if err := websocket.JSON.Send(_this.ws, h{
-		"id":     int(id),
-		"method": "Target.sendMessageToTarget",
-		"params": h{"message": string(b), "sessionId": _this.session},
-	}); err != nil {
-		return nil, err
+	// Write errors come back through resc, see writeLoop
+	select {
+	case _this.outgoing <- outboundFrame{id: int(id), data: b}:
+	case res := <-resc:
+		return res.Value, res.Err
+	case <-ctx.Done():
+		_this.Lock()
+		delete(_this.pending, int(id))
+		_this.Unlock()
+		return nil, ctx.Err()
+	}
+
+	select {
+	case res := <-resc:
+		return res.Value, res.Err
+	case <-ctx.Done():
+		_this.Lock()
+		delete(_this.pending, int(id))
+		_this.Unlock()
+		return nil, ctx.Err()
 	}
-	res := <-resc
-	return res.Value, res.Err
 }
 
-func (_this *Browser) bind(name string, f bindingFunc) error {
-	_this.Lock()
-	// check if binding already exists
-	_, exists := _this.bindings[name]
+// writeLoop is the only writer of the transport once the browser is started, so
+// frames never interleave. A failed write fails the call that queued the frame.
+func (_this *Browser) writeLoop(conn Transport, outgoing <-chan outboundFrame, stopped <-chan struct{}) {
+	for {
+		select {
+		case f := <-outgoing:
+			if err := conn.Send(f.data); err != nil {
+				_this.fail(f.id, err)
+			}
+		case <-stopped:
+			return
+		}
+	}
+}
 
-	_this.bindings[name] = f
+// fail completes a pending call with an error.
+func (_this *Browser) fail(id int, err error) {
+	_this.Lock()
+	call, ok := _this.pending[id]
+	delete(_this.pending, id)
 	_this.Unlock()
 
-	if exists {
-		// Just replace callback and return, as the binding was already added to js
-		// and adding it again would break it.
-		return nil
+	if ok {
+		call.resc <- result{Err: err}
 	}
+}
 
-	if _, err := _this.send("Runtime.addBinding", h{"name": name}); err != nil {
-		return err
+// failPending marks the browser as closed and fails every call still waiting for a reply.
+func (_this *Browser) failPending() {
+	_this.Lock()
+	defer _this.Unlock()
+
+	if !_this.closed {
+		close(_this.stopped)
+	}
+
+	_this.closed = true
+
+	for id, call := range _this.pending {
+		call.resc <- result{Err: ErrBrowserClosed}
+		delete(_this.pending, id)
 	}
-	script := fmt.Sprintf(`(() => {
-	const bindingName = '%s';
-	const binding = window[bindingName];
-	window[bindingName] = async (...args) => {
-		const me = window[bindingName];
-		let errors = me['errors'];
-		let callbacks = me['callbacks'];
-		if (!callbacks) {
-			callbacks = new Map();
-			me['callbacks'] = callbacks;
+
+	for session, page := range _this.pages {
+		page.close()
+		delete(_this.pages, session)
+	}
+}
+
+// detach forgets a page whose target was destroyed or whose session was detached,
+// failing the calls still waiting on its session. It reports whether it was the last page.
+func (_this *Browser) detach(target string, session string) bool {
+	_this.Lock()
+	defer _this.Unlock()
+
+	detached := false
+
+	for s, page := range _this.pages {
+
+		if page.target != target && s != session {
+			continue
 		}
-		if (!errors) {
-			errors = new Map();
-			me['errors'] = errors;
+
+		for id, call := range _this.pending {
+			if call.session == s {
+				call.resc <- result{Err: ErrPageClosed}
+				delete(_this.pending, id)
+			}
 		}
-		const seq = (me['lastSeq'] || 0) + 1;
-		me['lastSeq'] = seq;
-		const promise = new Promise((resolve, reject) => {
-			callbacks.set(seq, resolve);
-			errors.set(seq, reject);
-		});
-		binding(JSON.stringify({name: bindingName, seq, args}));
-		return promise;
-	}})();
-	`, name)
-	_, err := _this.send("Page.addScriptToEvaluateOnNewDocument", h{"source": script})
-	if err != nil {
-		return err
+
+		page.close()
+		delete(_this.pages, s)
+		detached = true
 	}
 
-	awaitPromise := true
-	returnByValue := true
+	return detached && len(_this.pages) == 0
+}
+
+// addPage registers a page for an attached session.
+func (_this *Browser) addPage(target string, session string) *Page {
+
+	page := newPage(_this, target, session)
+
+	_this.Lock()
+	_this.pages[session] = page
+	_this.Unlock()
 
-	_, err = _this.RuntimeEvaluate(RuntimeEvaluateParameters{Expression: script, AwaitPromise: &awaitPromise, ReturnByValue: &returnByValue})
+	return page
+}
 
-	return err
+// Page returns the page of the window opened when the browser started.
+func (_this *Browser) Page() *Page {
+	return _this.page
 }
 
-func (_this *Browser) setBounds(b Bounds) error {
-	if b.WindowState == "" {
-		b.WindowState = WindowStateNormal
+// Pages returns every page attached to the browser.
+func (_this *Browser) Pages() []*Page {
+	_this.Lock()
+	defer _this.Unlock()
+
+	pages := make([]*Page, 0, len(_this.pages))
+
+	for _, page := range _this.pages {
+		pages = append(pages, page)
 	}
-	param := h{"windowId": _this.window, "bounds": b}
-	if b.WindowState != WindowStateNormal {
-		param["bounds"] = h{"windowState": b.WindowState}
+
+	return pages
+}
+
+// NewPage opens url in a new window and returns its page.
+func (_this *Browser) NewPage(url string) (*Page, error) {
+
+	res, err := _this.send("Target.createTarget", h{"url": url, "newWindow": true})
+	if err != nil {
+		return nil, err
+	}
+
+	target := struct {
+		ID string `json:"targetId"`
+	}{}
+	if err := json.Unmarshal(res, &target); err != nil {
+		return nil, err
 	}
-	_, err := _this.send("Browser.setWindowBounds", param)
-	return err
+
+	return _this.AttachPage(target.ID)
 }
 
-func (_this *Browser) bounds() (Bounds, error) {
-	result, err := _this.send("Browser.getWindowBounds", h{"windowId": _this.window})
+// AttachPage attaches to an existing target, e.g. one found with Target.getTargets, and returns its page.
+func (_this *Browser) AttachPage(targetID string) (*Page, error) {
+
+	_this.Lock()
+	for _, page := range _this.pages {
+		if page.target == targetID {
+			_this.Unlock()
+			return page, nil
+		}
+	}
+	_this.Unlock()
+
+	res, err := _this.send("Target.attachToTarget", h{"targetId": targetID, "flatten": true})
 	if err != nil {
-		return Bounds{}, err
+		return nil, err
 	}
-	bounds := struct {
-		Bounds Bounds `json:"bounds"`
+
+	session := struct {
+		ID string `json:"sessionId"`
 	}{}
-	err = json.Unmarshal(result, &bounds)
-	return bounds.Bounds, err
+	if err := json.Unmarshal(res, &session); err != nil {
+		return nil, err
+	}
+
+	page := _this.addPage(targetID, session.ID)
+
+	if err := page.init(); err != nil {
+		return nil, err
+	}
+
+	return page, nil
 }
 
 func (_this *Browser) kill(exited bool) error {
 
-	if _this.ws != nil {
+	_this.failPending()
+
+	if _this.conn != nil {
 
-		if err := _this.ws.Close(); err != nil {
+		if err := _this.conn.Close(); err != nil {
 			return err
 		}
 
 	}
 
-	if exited {
+	if exited || _this.cmd == nil {
 		return nil
 	}
 
-	// TODO: cancel all pending requests
 	if state := _this.cmd.ProcessState; state == nil || !state.Exited() {
 
 		sig := os.Interrupt
@@ -399,74 +586,15 @@ func structToMap(s interface{}) h {
 	return aux
 }
 
-func (_this *Browser) Bind(name string, f interface{}) error {
-	v := reflect.ValueOf(f)
-	// f must be a function
-	if v.Kind() != reflect.Func {
-		return errors.New("only functions can be bound")
-	}
-	// f must return either value and error or just error
-	if n := v.Type().NumOut(); n > 2 {
-		return errors.New("function may only return a value or a value+error")
-	}
+// SendContext sends a raw DevTools Protocol command and returns its result.
+// It stops waiting and returns ctx.Err() when the context is done.
+func (_this *Browser) SendContext(ctx context.Context, method string, params map[string]interface{}) (json.RawMessage, error) {
 
-	return _this.bind(name, func(raw []json.RawMessage) (interface{}, error) {
-		if len(raw) != v.Type().NumIn() {
-			return nil, errors.New("function arguments mismatch")
-		}
-		args := []reflect.Value{}
-		for i := range raw {
-			arg := reflect.New(v.Type().In(i))
-			if err := json.Unmarshal(raw[i], arg.Interface()); err != nil {
-				return nil, err
-			}
-			args = append(args, arg.Elem())
-		}
-		errorType := reflect.TypeOf((*error)(nil)).Elem()
-		res := v.Call(args)
-		switch len(res) {
-		case 0:
-			// No results from the function, just return nil
-			return nil, nil
-		case 1:
-			// One result may be a value, or an error
-			if res[0].Type().Implements(errorType) {
-				if res[0].Interface() != nil {
-					return nil, res[0].Interface().(error)
-				}
-				return nil, nil
-			}
-			return res[0].Interface(), nil
-		case 2:
-			// Two results: first one is value, second is error
-			if !res[1].Type().Implements(errorType) {
-				return nil, errors.New("second return value must be an error")
-			}
-			if res[1].Interface() == nil {
-				return res[0].Interface(), nil
-			}
-			return res[0].Interface(), res[1].Interface().(error)
-		default:
-			return nil, errors.New("unexpected number of return values")
-		}
-	})
-}
-
-func (_this *Browser) Eval(js string) Value {
-
-	awaitPromise := true
-	returnByValue := true
-
-	v, err := _this.RuntimeEvaluate(RuntimeEvaluateParameters{Expression: js, AwaitPromise: &awaitPromise, ReturnByValue: &returnByValue})
-	return value{err: err, raw: v}
-}
-
-func (_this *Browser) SetBounds(b Bounds) error {
-	return _this.setBounds(b)
-}
+	if params == nil {
+		params = h{}
+	}
 
-func (_this *Browser) Bounds() (Bounds, error) {
-	return _this.bounds()
+	return _this.sendContext(ctx, method, params)
 }
 
 func (_this *Browser) browserBinary() string {
@@ -576,7 +704,8 @@ func (_this *Browser) Close() error {
 	_this.kill(false)
 	<-_this.done
 
-	if !_this.config.UserDataDirKeep {
+	// The profile of a browser proton attached to is not its own to remove
+	if !_this.config.UserDataDirKeep && _this.cmd != nil {
 		if err := os.RemoveAll(_this.config.UserDataDir); err != nil {
 			return err
 		}
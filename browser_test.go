@@ -0,0 +1,956 @@
+package proton_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/leandroveronezi/proton"
+	"github.com/leandroveronezi/proton/protontest"
+	"golang.org/x/net/websocket"
+)
+
+func connect(t *testing.T) (*proton.Browser, *protontest.Peer) {
+	t.Helper()
+
+	peer := protontest.NewPeer()
+	browser := &proton.Browser{}
+
+	if err := browser.ConnectTransport(peer); err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { peer.Close() })
+
+	return browser, peer
+}
+
+func TestEval(t *testing.T) {
+	browser, peer := connect(t)
+
+	peer.Handle("Runtime.evaluate", func(params json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"result": map[string]interface{}{"type": "number", "value": 42}}, nil
+	})
+
+	if v := browser.Page().Eval("6 * 7"); v.Err() != nil || v.Int() != 42 {
+		t.Fatal(v.Err(), v.Int())
+	}
+
+	peer.Handle("Runtime.evaluate", func(params json.RawMessage) (interface{}, error) {
+		return nil, &proton.ProtocolError{Code: proton.ErrCodeInvalidParams, Message: "Invalid parameters"}
+	})
+
+	var perr *proton.ProtocolError
+	if err := browser.Page().Eval("").Err(); !errors.As(err, &perr) || perr.Method != "Runtime.evaluate" {
+		t.Fatal(err)
+	}
+}
+
+func TestBind(t *testing.T) {
+	browser, peer := connect(t)
+
+	if err := browser.Page().Bind("add", func(a, b int) int { return a + b }); err != nil {
+		t.Fatal(err)
+	}
+	if len(peer.Commands("Runtime.addBinding")) != 1 {
+		t.Fatal("binding not added")
+	}
+
+	res, err := peer.CallBinding("add", 1, 2)
+	if err != nil || string(res) != "3" {
+		t.Fatal(string(res), err)
+	}
+
+	if _, err := peer.CallBinding("add", 1, 2, 3); err == nil {
+		t.Fatal("expected arguments mismatch")
+	}
+}
+
+func TestBindArguments(t *testing.T) {
+	browser, peer := connect(t)
+
+	browser.Page().Bind("greet", func(name string, title *string) string {
+		if title == nil {
+			return "hello " + name
+		}
+		return "hello " + *title + " " + name
+	})
+	browser.Page().Bind("sum", func(label string, values ...int) string {
+		total := 0
+		for _, v := range values {
+			total += v
+		}
+		return fmt.Sprintf("%s=%d", label, total)
+	})
+
+	if res, err := peer.CallBinding("greet", "ada"); err != nil || string(res) != `"hello ada"` {
+		t.Fatal(string(res), err)
+	}
+	if res, err := peer.CallBinding("sum", "none"); err != nil || string(res) != `"none=0"` {
+		t.Fatal(string(res), err)
+	}
+	if res, err := peer.CallBinding("sum", "all", 1, 2, 3); err != nil || string(res) != `"all=6"` {
+		t.Fatal(string(res), err)
+	}
+
+	_, err := peer.CallBinding("sum", "bad", 1, "two")
+	var berr *protontest.BindingError
+	if !errors.As(err, &berr) {
+		t.Fatal(err)
+	}
+	reason := struct {
+		Code    string
+		Message string
+		Data    map[string]interface{}
+	}{}
+	json.Unmarshal(berr.Value, &reason)
+	if reason.Code != proton.BindingArgumentCode || reason.Data["index"] != 2.0 || reason.Data["type"] != "int" || !strings.HasPrefix(reason.Message, "argument 2: expected int:") {
+		t.Fatal(string(berr.Value))
+	}
+}
+
+func TestOn(t *testing.T) {
+	browser, peer := connect(t)
+
+	events, cancel := browser.Page().Subscribe("Page.loadEventFired")
+	defer cancel()
+
+	peer.Emit(peer.Sessions()[0], "Page.loadEventFired", map[string]interface{}{"timestamp": 1})
+
+	select {
+	case params := <-events:
+		if string(params) != `{"timestamp":1}` {
+			t.Fatal(string(params))
+		}
+	case <-time.After(time.Second):
+		t.Fatal("event not delivered")
+	}
+}
+
+func TestSubscribeClosed(t *testing.T) {
+	browser, peer := connect(t)
+
+	ranged := func(c <-chan json.RawMessage) chan struct{} {
+		done := make(chan struct{})
+		go func() {
+			for range c {
+			}
+			close(done)
+		}()
+		return done
+	}
+	closed := func(done chan struct{}, why string) {
+		t.Helper()
+		select {
+		case <-done:
+		case <-time.After(time.Second):
+			t.Fatal("channel not closed on " + why)
+		}
+	}
+
+	events, cancel := browser.Subscribe("Page.loadEventFired")
+	cancel()
+	closed(ranged(events), "cancel")
+	cancel()
+
+	page, err := browser.NewPage("about:blank")
+	if err != nil {
+		t.Fatal(err)
+	}
+	events, _ = page.Subscribe("Page.loadEventFired")
+	peer.Emit("", "Target.targetDestroyed", map[string]interface{}{"targetId": page.TargetID()})
+	closed(ranged(events), "page closed")
+
+	events, _ = browser.Subscribe("Page.loadEventFired")
+	peer.Close()
+	closed(ranged(events), "browser closed")
+
+	<-browser.Done()
+	events, _ = browser.Subscribe("Page.loadEventFired")
+	closed(ranged(events), "subscribe after close")
+}
+
+func TestSlowHandlers(t *testing.T) {
+	browser, peer := connect(t)
+
+	// A subscriber that never reads, and a handler waiting for replies
+	_, cancel := browser.Subscribe("Network.dataReceived")
+	defer cancel()
+
+	handled := make(chan struct{}, 1000)
+	browser.On("Network.dataReceived", func(json.RawMessage) {
+		browser.Page().Eval("1")
+		handled <- struct{}{}
+	})
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < 600; i++ {
+			peer.Emit(peer.Sessions()[0], "Network.dataReceived", map[string]interface{}{"dataLength": i})
+		}
+		for i := 0; i < 600; i++ {
+			<-handled
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("events blocked the connection")
+	}
+}
+
+func TestSendContext(t *testing.T) {
+	browser, peer := connect(t)
+
+	block := make(chan struct{})
+	defer close(block)
+
+	peer.Handle("Runtime.evaluate", func(params json.RawMessage) (interface{}, error) {
+		<-block
+		return nil, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	if err := browser.Page().EvalContext(ctx, "1").Err(); err != context.DeadlineExceeded {
+		t.Fatal(err)
+	}
+}
+
+func TestBrowserClosed(t *testing.T) {
+	browser, peer := connect(t)
+
+	peer.Close()
+
+	select {
+	case <-browser.Done():
+	case <-time.After(time.Second):
+		t.Fatal("browser not done")
+	}
+
+	if err := browser.Page().Eval("1").Err(); err != proton.ErrBrowserClosed {
+		t.Fatal(err)
+	}
+}
+
+func TestFirstPageClosed(t *testing.T) {
+	browser, peer := connect(t)
+
+	page, err := browser.NewPage("about:blank")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	peer.Emit("", "Target.targetDestroyed", map[string]interface{}{"targetId": browser.Page().TargetID()})
+
+	select {
+	case <-browser.Page().Done():
+	case <-time.After(time.Second):
+		t.Fatal("page not done")
+	}
+
+	select {
+	case <-browser.Done():
+		t.Fatal("browser done with a page still open")
+	default:
+	}
+
+	if err := page.Eval("1").Err(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Calls on the closed page fail at once, without a deadline
+	if err := browser.Page().Eval("1").Err(); err != proton.ErrPageClosed || !errors.Is(err, proton.ErrBrowserClosed) {
+		t.Fatal(err)
+	}
+
+	// The last page gone, so is the browser
+	peer.Emit("", "Target.targetDestroyed", map[string]interface{}{"targetId": page.TargetID()})
+
+	select {
+	case <-browser.Done():
+	case <-time.After(time.Second):
+		t.Fatal("browser not done")
+	}
+
+	if _, err := browser.NewPage("about:blank"); err != proton.ErrBrowserClosed {
+		t.Fatal(err)
+	}
+}
+
+// lostSession sends the replies of the peer with the session of a page detached meanwhile.
+type lostSession struct {
+	*protontest.Peer
+	lost int32
+}
+
+func (_this *lostSession) Receive() ([]byte, error) {
+
+	data, err := _this.Peer.Receive()
+	if err == nil && atomic.LoadInt32(&_this.lost) == 1 && bytes.HasPrefix(data, []byte(`{"id":`)) {
+		// The last key wins
+		data = append(data[:len(data)-1:len(data)-1], `,"sessionId":"session-gone"}`...)
+	}
+
+	return data, err
+}
+
+func TestReplyOfDetachedSession(t *testing.T) {
+
+	peer := &lostSession{Peer: protontest.NewPeer()}
+	defer peer.Close()
+
+	browser := &proton.Browser{}
+	if err := browser.ConnectTransport(peer); err != nil {
+		t.Fatal(err)
+	}
+
+	atomic.StoreInt32(&peer.lost, 1)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	if err := browser.Page().EvalContext(ctx, "1").Err(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConnectHTTP(t *testing.T) {
+
+	// A headless browser, its targets have no window
+	peer := protontest.NewPeer()
+	peer.Handle("Browser.getWindowForTarget", func(json.RawMessage) (interface{}, error) {
+		return nil, errors.New("Browser window not found")
+	})
+	peer.Handle("Runtime.evaluate", func(json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"result": map[string]interface{}{"type": "number", "value": 42}}, nil
+	})
+	defer peer.Close()
+
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	mux.HandleFunc("/json/version", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]string{"webSocketDebuggerUrl": "ws" + strings.TrimPrefix(server.URL, "http") + "/devtools/browser/1"})
+	})
+	mux.Handle("/devtools/browser/1", websocket.Handler(func(ws *websocket.Conn) {
+		go func() {
+			for {
+				data, err := peer.Receive()
+				if err != nil || websocket.Message.Send(ws, string(data)) != nil {
+					return
+				}
+			}
+		}()
+		for {
+			var data []byte
+			if websocket.Message.Receive(ws, &data) != nil || peer.Send(data) != nil {
+				return
+			}
+		}
+	}))
+
+	browser := &proton.Browser{}
+	if err := browser.ConnectHTTP(server.URL + "/"); err != nil {
+		t.Fatal(err)
+	}
+	defer browser.Close()
+
+	if v := browser.Page().Eval("6 * 7"); v.Err() != nil || v.Int() != 42 {
+		t.Fatal(v.Err(), v.Int())
+	}
+}
+
+func TestConnectHTTPTimeout(t *testing.T) {
+
+	// Accepts the connection, never answers
+	block := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { <-block }))
+	defer server.Close()
+	defer close(block)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	browser := &proton.Browser{}
+	if err := browser.ConnectHTTPContext(ctx, server.URL); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatal(err)
+	}
+}
+
+func TestCloseAttached(t *testing.T) {
+
+	dir := t.TempDir()
+
+	peer := protontest.NewPeer()
+	browser := &proton.Browser{}
+	if err := browser.ConnectTransport(peer, proton.Config{UserDataDir: dir}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := browser.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(dir); err != nil {
+		t.Fatal("profile of the attached browser removed:", err)
+	}
+}
+
+func TestRecordReplay(t *testing.T) {
+	var recording bytes.Buffer
+
+	peer := protontest.NewPeer()
+	peer.Handle("Runtime.evaluate", func(params json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"result": map[string]interface{}{"type": "string", "value": "recorded"}}, nil
+	})
+
+	browser := &proton.Browser{}
+	if err := browser.ConnectTransport(peer, proton.Config{Recorder: &recording}); err != nil {
+		t.Fatal(err)
+	}
+	if v := browser.Page().Eval("document.title"); v.String() != "recorded" {
+		t.Fatal(v.Err())
+	}
+	peer.Close()
+	<-browser.Done()
+
+	replay, err := proton.NewReplayTransport(bytes.NewReader(recording.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	browser = &proton.Browser{}
+	if err := browser.ConnectTransport(replay); err != nil {
+		t.Fatal(err)
+	}
+	if v := browser.Page().Eval("document.title"); v.String() != "recorded" {
+		t.Fatal(v.Err())
+	}
+}
+
+func TestConcurrentCalls(t *testing.T) {
+	browser, peer := connect(t)
+
+	before := len(peer.Commands("Runtime.evaluate"))
+
+	errs := make(chan error)
+	for i := 0; i < 50; i++ {
+		go func() {
+			errs <- browser.Page().Eval("1").Err()
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		if err := <-errs; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := len(peer.Commands("Runtime.evaluate")) - before; n != 50 {
+		t.Fatal(n)
+	}
+}
+
+type calculator struct {
+	base int
+}
+
+func (c calculator) Add(a, b int) int { return c.base + a + b }
+
+func (c *calculator) Reset() { c.base = 0 }
+
+func TestBindObject(t *testing.T) {
+	browser, peer := connect(t)
+
+	if err := browser.Page().BindObject("calc", &calculator{base: 10}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(peer.Commands("Runtime.addBinding")); n != 2 {
+		t.Fatal(n)
+	}
+
+	res, err := peer.CallBinding("calc.Add", 1, 2)
+	if err != nil || string(res) != "13" {
+		t.Fatal(string(res), err)
+	}
+	if _, err := peer.CallBinding("calc.Reset"); err != nil {
+		t.Fatal(err)
+	}
+	if res, _ := peer.CallBinding("calc.Add", 1, 2); string(res) != "3" {
+		t.Fatal(string(res))
+	}
+
+	if err := browser.Page().BindObject("nothing", nil); err == nil {
+		t.Fatal("expected an error for nil")
+	}
+	if err := browser.Page().BindObject("broken", broken{}); err == nil || len(peer.Commands("Runtime.addBinding")) != 2 {
+		t.Fatal(err, browser.Page().Bindings())
+	}
+}
+
+// broken has a method that can't be bound after one that can
+type broken struct{}
+
+func (broken) Fine() int              { return 0 }
+func (broken) Wrong() (int, int, int) { return 0, 0, 0 }
+
+func TestBindContext(t *testing.T) {
+	browser, peer := connect(t)
+
+	started := make(chan struct{}, 1)
+	cancelled := make(chan error, 1)
+
+	browser.Page().Bind("wait", func(ctx context.Context, label string) string {
+		started <- struct{}{}
+		<-ctx.Done()
+		cancelled <- ctx.Err()
+		return label
+	})
+
+	// Aborted from JS
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	if _, err := peer.CallBindingContext(ctx, "wait", "abort"); err != context.Canceled {
+		t.Fatal(err)
+	}
+	if err := <-cancelled; err != context.Canceled {
+		t.Fatal(err)
+	}
+
+	// Page navigated away
+	go func() {
+		<-started
+		peer.Emit(peer.Sessions()[0], "Runtime.executionContextDestroyed", map[string]interface{}{"executionContextId": 1})
+	}()
+	if res, err := peer.CallBinding("wait", "navigate"); err != nil || string(res) != `"navigate"` {
+		t.Fatal(string(res), err)
+	}
+}
+
+type notFound string
+
+func (e notFound) Error() string       { return string(e) + " not found" }
+func (e notFound) JSCode() string      { return "NOT_FOUND" }
+func (e notFound) JSData() interface{} { return map[string]string{"id": string(e)} }
+
+type explosive struct{}
+
+func (explosive) MarshalJSON() ([]byte, error) { panic("boom") }
+func (explosive) Read([]byte) (int, error)     { panic("boom") }
+
+func TestBindErrors(t *testing.T) {
+	browser, peer := connect(t)
+
+	browser.Page().Bind("explode", func() int { panic("boom") })
+	browser.Page().Bind("marshal", func() interface{} { return []explosive{{}} })
+	browser.Page().Bind("read", func() io.Reader { return explosive{} })
+	browser.Page().Bind("find", func(id string) (string, error) { return "", notFound(id) })
+
+	reason := func(err error) map[string]interface{} {
+		t.Helper()
+		var berr *protontest.BindingError
+		if !errors.As(err, &berr) {
+			t.Fatal(err)
+		}
+		m := map[string]interface{}{}
+		json.Unmarshal(berr.Value, &m)
+		return m
+	}
+
+	_, err := peer.CallBinding("explode")
+	if r := reason(err); r["name"] != "GoPanic" || r["code"] != proton.BindingPanicCode || r["message"] != "panic: boom" {
+		t.Fatal(r)
+	}
+
+	for _, name := range []string{"marshal", "read"} {
+		_, err = peer.CallBinding(name)
+		if r := reason(err); r["name"] != "GoPanic" || !strings.Contains(r["message"].(string), "boom") {
+			t.Fatal(name, r)
+		}
+	}
+
+	_, err = peer.CallBinding("find", "42")
+	if r := reason(err); r["code"] != "NOT_FOUND" || r["message"] != "42 not found" || r["data"].(map[string]interface{})["id"] != "42" {
+		t.Fatal(r)
+	}
+}
+
+type article struct {
+	Title   string   `json:"title"`
+	Tags    []string `json:"tags,omitempty"`
+	Related *article `json:"related"`
+	hidden  bool
+}
+
+// checksum has a byte array, encoded as numbers unlike a byte slice, and a nullable time
+type checksum struct {
+	Sum     [4]byte    `json:"sum"`
+	Checked *time.Time `json:"checked"`
+}
+
+func TestTypeScriptDefinitions(t *testing.T) {
+	browser, _ := connect(t)
+
+	browser.Page().Bind("search", func(ctx context.Context, query string, limit int) ([]article, error) { return nil, nil })
+	browser.Page().BindObject("calc", &calculator{})
+	browser.Page().Bind("scan", func(path string, progress func(float64)) error { return nil })
+	browser.Page().Bind("tag", func(id int, label *string, extra ...string) {})
+	browser.Page().Bind("compress", func(data []byte, extra io.Reader) ([]byte, error) { return nil, nil })
+	browser.Page().Bind("checksum", func() checksum { return checksum{} })
+
+	defs := browser.Page().TypeScriptDefinitions()
+
+	for _, want := range []string{
+		"export interface article {\n\ttitle: string;\n\ttags?: string[] | null;\n\trelated: article | null;\n}",
+		"\t\tsearch(arg0: string, arg1: number, signal?: AbortSignal): Promise<article[] | null>;",
+		"\t\tscan(arg0: string): AsyncIterable<number>;",
+		"\t\tcompress(arg0: BufferSource | Blob, arg1: BufferSource | Blob | string): Promise<Uint8Array | null>;",
+		"\t\ttag(arg0: number, arg1?: string | null, ...arg2: string[]): Promise<void>;",
+		"export interface checksum {\n\tsum: number[];\n\tchecked: string | null;\n}",
+		"\t\tcalc: {\n\t\t\tAdd(arg0: number, arg1: number): Promise<number>;\n\t\t\tReset(): Promise<void>;\n\t\t};",
+	} {
+		if !strings.Contains(defs, want) {
+			t.Fatalf("missing %q in\n%s", want, defs)
+		}
+	}
+}
+
+func TestEmit(t *testing.T) {
+	browser, peer := connect(t)
+
+	scripts := peer.Commands("Page.addScriptToEvaluateOnNewDocument")
+	if len(scripts) == 0 || !strings.Contains(string(scripts[0].Params), "proton.on") {
+		t.Fatal(scripts)
+	}
+
+	if err := browser.Emit("progress", map[string]interface{}{"done": 1, "label": "</script>'\""}); err != nil {
+		t.Fatal(err)
+	}
+
+	evals := peer.Commands("Runtime.evaluate")
+	params := struct {
+		Expression string `json:"expression"`
+	}{}
+	json.Unmarshal(evals[len(evals)-1].Params, &params)
+
+	if want := `window.proton.dispatch("progress", {"done":1,"label":"\u003c/script\u003e'\""})`; !strings.HasSuffix(params.Expression, want) {
+		t.Fatal(params.Expression)
+	}
+
+	if err := browser.Emit("bad", func() {}); err == nil {
+		t.Fatal("expected an encoding error")
+	}
+}
+
+func TestCall(t *testing.T) {
+	browser, peer := connect(t)
+
+	peer.Handle("Runtime.callFunctionOn", func(params json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"result": map[string]interface{}{"type": "string", "value": "ok"}}, nil
+	})
+
+	if v := browser.Page().Call("function(s, n, f) { return s + n + f }", "'); alert(1); ('", 9007199254740993, math.Inf(1)); v.Err() != nil || v.String() != "ok" {
+		t.Fatal(v.Err())
+	}
+
+	calls := peer.Commands("Runtime.callFunctionOn")
+	if got, want := string(calls[len(calls)-1].Params), `{"arguments":[{"value":"'); alert(1); ('"},{"value":9007199254740993},{"unserializableValue":"Infinity"}],"awaitPromise":true,"executionContextId":1,"functionDeclaration":"function(s, n, f) { return s + n + f }","returnByValue":true}`; got != want {
+		t.Fatal(got)
+	}
+
+	if err := browser.Page().Call("x => x", func() {}).Err(); err == nil || !strings.HasPrefix(err.Error(), "argument 0:") {
+		t.Fatal(err)
+	}
+
+	peer.Emit(peer.Sessions()[0], "Runtime.executionContextsCleared", map[string]interface{}{})
+	browser.Page().Eval("1") // Replied after the event was handled
+	if err := browser.Page().Call("() => 1").Err(); err != proton.ErrNoContext {
+		t.Fatal(err)
+	}
+}
+
+func TestRemoteObject(t *testing.T) {
+	browser, peer := connect(t)
+
+	node := map[string]interface{}{"type": "object", "subtype": "node", "className": "HTMLBodyElement", "objectId": "obj-1"}
+
+	peer.Handle("Runtime.evaluate", func(params json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"result": node}, nil
+	})
+	peer.Handle("Runtime.callFunctionOn", func(params json.RawMessage) (interface{}, error) {
+		if strings.Contains(string(params), `"returnByValue":true`) {
+			return map[string]interface{}{"result": map[string]interface{}{"type": "string", "value": "BODY"}}, nil
+		}
+		return map[string]interface{}{"result": map[string]interface{}{"type": "number", "value": 2}}, nil
+	})
+	peer.Handle("Runtime.getProperties", func(params json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"result": []interface{}{
+			map[string]interface{}{"name": "firstChild", "value": map[string]interface{}{"type": "object", "objectId": "obj-2"}},
+			map[string]interface{}{"name": "id", "value": map[string]interface{}{"type": "string", "value": "main"}},
+		}}, nil
+	})
+
+	group := browser.Page().NewObjectGroup()
+
+	body, err := group.EvalObject("document.body")
+	if err != nil || body.ObjectID() != "obj-1" || body.ClassName() != "HTMLBodyElement" {
+		t.Fatal(body, err)
+	}
+
+	if v := body.Call("function() { return this.tagName; }"); v.Err() != nil || v.String() != "BODY" {
+		t.Fatal(v.Err(), v.String())
+	}
+
+	count, err := body.Get("childElementCount")
+	if err != nil || count.ObjectID() != "" || count.Value().Int() != 2 {
+		t.Fatal(count, err)
+	}
+
+	props, err := body.Properties()
+	if err != nil || props["firstChild"].ObjectID() != "obj-2" || props["id"].Value().String() != "main" {
+		t.Fatal(props, err)
+	}
+
+	// Handles are passed by reference
+	browser.Page().Call("el => el.remove()", body)
+	calls := peer.Commands("Runtime.callFunctionOn")
+	if !strings.Contains(string(calls[len(calls)-1].Params), `"arguments":[{"objectId":"obj-1"}]`) {
+		t.Fatal(string(calls[len(calls)-1].Params))
+	}
+
+	if err := group.Release(); err != nil {
+		t.Fatal(err)
+	}
+	released := peer.Commands("Runtime.releaseObjectGroup")
+	if len(released) != 1 || !strings.Contains(string(released[0].Params), group.Name()) {
+		t.Fatal(released)
+	}
+
+	// An Error object is a handle like any other, not a failure
+	node = map[string]interface{}{"type": "object", "subtype": "error", "className": "TypeError", "description": "TypeError: x", "objectId": "obj-3"}
+
+	e, err := browser.Page().EvalObject("new TypeError('x')")
+	if err != nil || e.Subtype() != "error" || e.Description() != "TypeError: x" {
+		t.Fatal(e, err)
+	}
+}
+
+func TestBindStream(t *testing.T) {
+	browser, peer := connect(t)
+
+	browser.Page().Bind("count", func(n int) <-chan int {
+		c := make(chan int)
+		go func() {
+			defer close(c)
+			for i := 1; i <= n; i++ {
+				c <- i
+			}
+		}()
+		return c
+	})
+	browser.Page().Bind("scan", func(path string, progress func(string)) error {
+		progress(path + "/a")
+		progress(path + "/b")
+		return errors.New("permission denied")
+	})
+
+	items, err := peer.StreamBinding("count", 3)
+	if err != nil || len(items) != 3 || string(items[2]) != "3" {
+		t.Fatal(items, err)
+	}
+
+	// The callback is not passed from JS, its items are streamed before the error rejects the iteration
+	items, err = peer.StreamBinding("scan", "/tmp")
+	if err == nil || err.Error() != "permission denied" || len(items) != 2 || string(items[1]) != `"/tmp/b"` {
+		t.Fatal(items, err)
+	}
+
+	scripts := peer.Commands("Page.addScriptToEvaluateOnNewDocument")
+	if !strings.Contains(string(scripts[len(scripts)-1].Params), "const stream = true;") {
+		t.Fatal("stream stub not installed")
+	}
+}
+
+func TestBindBinary(t *testing.T) {
+	browser, peer := connect(t)
+
+	browser.Page().Bind("upload", func(header []byte, file io.Reader) (string, error) {
+		h := sha256.New()
+		n, err := io.Copy(h, file)
+		return fmt.Sprintf("%x:%d:%x", header, n, h.Sum(nil)[:4]), err
+	})
+	browser.Page().Bind("reverse", func(data []byte) []byte {
+		for i, j := 0, len(data)-1; i < j; i, j = i+1, j-1 {
+			data[i], data[j] = data[j], data[i]
+		}
+		return data
+	})
+
+	// Larger than a chunk, so the reader pulls it in several reads
+	file := bytes.Repeat([]byte("proton"), 400000)
+	sum := sha256.Sum256(file)
+
+	res, err := peer.CallBinding("upload", protontest.Uint8Array{0xca, 0xfe}, protontest.Blob{Name: "a.bin", Data: file})
+	if want := fmt.Sprintf(`"cafe:%d:%x"`, len(file), sum[:4]); err != nil || string(res) != want {
+		t.Fatal(string(res), err)
+	}
+
+	// A Blob passed for a []byte is read whole
+	res, err = peer.CallBinding("reverse", protontest.Blob{Data: []byte{1, 2, 3}})
+	if err != nil || string(res) != `{"$binary":"AwIB"}` {
+		t.Fatal(string(res), err)
+	}
+
+	// Named byte slices are binary too, unless they encode themselves like json.RawMessage
+	browser.Page().Bind("digest", func() digest { return digest{1, 2, 3} })
+	browser.Page().Bind("raw", func(v json.RawMessage) json.RawMessage { return v })
+
+	if res, err = peer.CallBinding("digest"); err != nil || string(res) != `{"$binary":"AQID"}` {
+		t.Fatal(string(res), err)
+	}
+	if res, err = peer.CallBinding("raw", map[string]int{"a": 1}); err != nil || string(res) != `{"a":1}` {
+		t.Fatal(string(res), err)
+	}
+
+	defs := browser.Page().TypeScriptDefinitions()
+	if !strings.Contains(defs, "digest(): Promise<Uint8Array | null>;") || !strings.Contains(defs, "raw(arg0: any): Promise<any>;") {
+		t.Fatal(defs)
+	}
+}
+
+type digest []byte
+
+func TestUnbind(t *testing.T) {
+	browser, peer := connect(t)
+	page := browser.Page()
+
+	page.Bind("add", func(a, b int) int { return a + b })
+	page.BindObject("calc", &calculator{})
+
+	if names := page.Bindings(); strings.Join(names, ",") != "add,calc.Add,calc.Reset" {
+		t.Fatal(names)
+	}
+
+	if err := page.Unbind("calc"); err != nil {
+		t.Fatal(err)
+	}
+	if names := page.Bindings(); strings.Join(names, ",") != "add" {
+		t.Fatal(names)
+	}
+	if n := len(peer.Commands("Runtime.removeBinding")); n != 2 {
+		t.Fatal(n)
+	}
+
+	// The stub scripts of calc.Add and calc.Reset, added after the helpers and add
+	removed := peer.Commands("Page.removeScriptToEvaluateOnNewDocument")
+	if len(removed) != 2 || string(removed[0].Params) != `{"identifier":"script-4"}` || string(removed[1].Params) != `{"identifier":"script-5"}` {
+		t.Fatal(removed)
+	}
+
+	if err := page.Unbind("calc"); err == nil {
+		t.Fatal("expected an error for a name not bound")
+	}
+
+	// Bound again from scratch
+	page.Bind("calc.Add", func(a, b int) int { return a * b })
+	if res, err := peer.CallBinding("calc.Add", 2, 3); err != nil || string(res) != "6" {
+		t.Fatal(string(res), err)
+	}
+}
+
+func TestBindingMiddleware(t *testing.T) {
+	browser, peer := connect(t)
+
+	calls := make(chan string, 10)
+
+	browser.UseBindingMiddleware(func(next proton.BindingHandler) proton.BindingHandler {
+		return func(ctx context.Context, call *proton.BindingCall) (interface{}, error) {
+			calls <- fmt.Sprintf("%s%s from %s (%d)", call.Name, call.Args, call.Origin, call.ContextID)
+			return next(ctx, call)
+		}
+	})
+	browser.UseBindingMiddleware(func(next proton.BindingHandler) proton.BindingHandler {
+		return func(ctx context.Context, call *proton.BindingCall) (interface{}, error) {
+			if call.Name == "delete" {
+				return nil, notFound("permission")
+			}
+			return next(ctx, call)
+		}
+	})
+
+	deleted := false
+	browser.Page().Bind("add", func(a, b int) int { return a + b })
+	browser.Page().Bind("delete", func() { deleted = true })
+
+	peer.SetOrigin("https://app.example")
+
+	if res, err := peer.CallBinding("add", 1, 2); err != nil || string(res) != "3" {
+		t.Fatal(string(res), err)
+	}
+	if call := <-calls; call != "add[1 2] from https://app.example (1)" {
+		t.Fatal(call)
+	}
+
+	var berr *protontest.BindingError
+	if _, err := peer.CallBinding("delete"); !errors.As(err, &berr) || !strings.Contains(string(berr.Value), `"code":"NOT_FOUND"`) || deleted {
+		t.Fatal(err, deleted)
+	}
+}
+
+func TestAllowOrigins(t *testing.T) {
+	browser, peer := connect(t)
+
+	browser.AllowOrigins("https://app.example/")
+	browser.Page().AllowOrigins("admin", "https://admin.example")
+
+	browser.Page().Bind("hello", func() string { return "world" })
+	browser.Page().BindObject("admin", &admin{})
+
+	denied := func(name string) bool {
+		var berr *protontest.BindingError
+		_, err := peer.CallBinding(name)
+		return errors.As(err, &berr) && strings.Contains(string(berr.Value), `"code":"GO_ORIGIN_NOT_ALLOWED"`)
+	}
+
+	peer.SetOrigin("https://app.example")
+	if _, err := peer.CallBinding("hello"); err != nil || !denied("admin.Reset") {
+		t.Fatal(err)
+	}
+
+	peer.SetOrigin("https://admin.example")
+	if _, err := peer.CallBinding("admin.Reset"); err != nil || !denied("hello") {
+		t.Fatal(err)
+	}
+
+	peer.SetOrigin("https://evil.example")
+	if !denied("hello") || !denied("admin.Reset") {
+		t.Fatal("call from an origin not allowed")
+	}
+
+	browser.AllowOrigins()
+	if _, err := peer.CallBinding("hello"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type admin struct{}
+
+func (_this *admin) Reset() {}
@@ -0,0 +1,305 @@
+// Command proton-dts writes the TypeScript declarations of the functions a Go package
+// binds with Page.Bind and Page.BindObject, without running it.
+//
+//	proton-dts -o bindings.d.ts ./examples/wikipedia
+//
+// Only bindings whose name is a constant string are found. The output is the same as
+// Page.TypeScriptDefinitions for the bindings made at run time.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"go/ast"
+	"go/constant"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"os"
+	"reflect"
+	"strings"
+
+	"github.com/leandroveronezi/proton/internal/tsgen"
+)
+
+const protonPath = "github.com/leandroveronezi/proton"
+
+func main() {
+
+	output := flag.String("o", "", "write the declarations to this file instead of stdout")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: proton-dts [-o file.d.ts] [package directory]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	dir := "."
+	if flag.NArg() > 0 {
+		dir = flag.Arg(0)
+	}
+
+	defs, err := load(dir)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "proton-dts:", err)
+		os.Exit(1)
+	}
+
+	if *output == "" {
+		fmt.Print(defs)
+		return
+	}
+
+	if err := os.WriteFile(*output, []byte(defs), 0644); err != nil {
+		fmt.Fprintln(os.Stderr, "proton-dts:", err)
+		os.Exit(1)
+	}
+}
+
+// load type-checks the package in dir and declares the bindings it makes.
+func load(dir string) (string, error) {
+
+	fset := token.NewFileSet()
+
+	pkgs, err := parser.ParseDir(fset, dir, func(fi os.FileInfo) bool {
+		return !strings.HasSuffix(fi.Name(), "_test.go")
+	}, 0)
+	if err != nil {
+		return "", err
+	}
+
+	files := []*ast.File{}
+	for _, pkg := range pkgs {
+		for _, f := range pkg.Files {
+			files = append(files, f)
+		}
+	}
+	if len(files) == 0 {
+		return "", fmt.Errorf("no Go files in %s", dir)
+	}
+
+	info := &types.Info{
+		Types:      map[ast.Expr]types.TypeAndValue{},
+		Selections: map[*ast.SelectorExpr]*types.Selection{},
+	}
+
+	conf := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	if _, err := conf.Check(dir, fset, files, info); err != nil {
+		return "", err
+	}
+
+	signatures := map[string]*types.Signature{}
+
+	for _, f := range files {
+		ast.Inspect(f, func(n ast.Node) bool {
+
+			call, ok := n.(*ast.CallExpr)
+			if !ok || len(call.Args) != 2 {
+				return true
+			}
+
+			sel, ok := call.Fun.(*ast.SelectorExpr)
+			if !ok || !isPageMethod(info.Selections[sel]) {
+				return true
+			}
+
+			name := info.Types[call.Args[0]].Value
+			if name == nil || name.Kind() != constant.String {
+				return true
+			}
+			prefix := constant.StringVal(name)
+
+			t := info.TypeOf(call.Args[1])
+
+			switch sel.Sel.Name {
+			case "Bind":
+				if s, ok := t.Underlying().(*types.Signature); ok {
+					signatures[prefix] = s
+				}
+			case "BindObject":
+				methods := types.NewMethodSet(t)
+				for i := 0; i < methods.Len(); i++ {
+					m := methods.At(i).Obj()
+					if m.Exported() {
+						signatures[prefix+"."+m.Name()] = m.Type().(*types.Signature)
+					}
+				}
+			}
+
+			return true
+		})
+	}
+
+	bindings := map[string]tsgen.Type{}
+	for name, s := range signatures {
+		bindings[name] = tsType{s}
+	}
+
+	return tsgen.Declare(bindings).String(), nil
+}
+
+// isPageMethod reports whether sel selects Bind or BindObject of a proton.Page.
+func isPageMethod(sel *types.Selection) bool {
+
+	if sel == nil || sel.Kind() != types.MethodVal {
+		return false
+	}
+
+	if name := sel.Obj().Name(); name != "Bind" && name != "BindObject" {
+		return false
+	}
+
+	return isNamed(sel.Recv(), protonPath, "Page")
+}
+
+func isNamed(t types.Type, pkg string, name string) bool {
+
+	if p, ok := t.(*types.Pointer); ok {
+		t = p.Elem()
+	}
+
+	n, ok := t.(*types.Named)
+	if !ok || n.Obj().Pkg() == nil {
+		return false
+	}
+
+	return n.Obj().Pkg().Path() == pkg && n.Obj().Name() == name
+}
+
+// tsType describes a go/types type for the declarations of internal/tsgen,
+// like Page.TypeScriptDefinitions does with a reflect.Type.
+type tsType struct {
+	types.Type
+}
+
+func (_this tsType) Kind() tsgen.Kind {
+
+	switch u := _this.Underlying().(type) {
+	case *types.Basic:
+		switch {
+		case u.Kind() == types.Uint8:
+			return tsgen.KindByte
+		case u.Info()&types.IsBoolean != 0:
+			return tsgen.KindBool
+		case u.Info()&(types.IsInteger|types.IsFloat) != 0:
+			return tsgen.KindNumber
+		case u.Info()&types.IsString != 0:
+			return tsgen.KindString
+		}
+	case *types.Pointer:
+		return tsgen.KindPointer
+	case *types.Slice:
+		return tsgen.KindSlice
+	case *types.Array:
+		return tsgen.KindArray
+	case *types.Map:
+		return tsgen.KindMap
+	case *types.Struct:
+		return tsgen.KindStruct
+	case *types.Signature:
+		return tsgen.KindFunc
+	case *types.Chan:
+		return tsgen.KindChan
+	}
+
+	return tsgen.KindOther
+}
+
+func (_this tsType) Named() (string, string) {
+
+	n, ok := _this.Type.(*types.Named)
+	if !ok {
+		return "", ""
+	}
+
+	if n.Obj().Pkg() == nil {
+		return "", n.Obj().Name()
+	}
+
+	return n.Obj().Pkg().Path(), n.Obj().Name()
+}
+
+func (_this tsType) ID() interface{} {
+
+	if n, ok := _this.Type.(*types.Named); ok {
+		return n.Obj()
+	}
+
+	return _this.Type
+}
+
+func (_this tsType) HasMethod(name string, pointer bool) bool {
+
+	if types.NewMethodSet(_this.Type).Lookup(nil, name) != nil {
+		return true
+	}
+
+	if _, ok := _this.Underlying().(*types.Interface); !pointer || ok || isPointer(_this.Type) {
+		return false
+	}
+
+	return types.NewMethodSet(types.NewPointer(_this.Type)).Lookup(nil, name) != nil
+}
+
+func isPointer(t types.Type) bool {
+	_, ok := t.(*types.Pointer)
+	return ok
+}
+
+func (_this tsType) Elem() tsgen.Type {
+
+	switch u := _this.Underlying().(type) {
+	case *types.Pointer:
+		return tsType{u.Elem()}
+	case *types.Slice:
+		return tsType{u.Elem()}
+	case *types.Array:
+		return tsType{u.Elem()}
+	case *types.Map:
+		return tsType{u.Elem()}
+	case *types.Chan:
+		return tsType{u.Elem()}
+	}
+
+	return nil
+}
+
+func (_this tsType) Recv() bool {
+	return _this.Underlying().(*types.Chan).Dir() != types.SendOnly
+}
+
+func (_this tsType) Fields() []tsgen.StructField {
+
+	st := _this.Underlying().(*types.Struct)
+	fields := make([]tsgen.StructField, st.NumFields())
+
+	for i := range fields {
+		f := st.Field(i)
+		tag := reflect.StructTag(st.Tag(i)).Get("json")
+		fields[i] = tsgen.StructField{Name: f.Name(), Tag: tag, Embedded: f.Embedded(), Exported: f.Exported(), Type: tsType{f.Type()}}
+	}
+
+	return fields
+}
+
+func (_this tsType) Params() []tsgen.Type {
+	return tuple(_this.Underlying().(*types.Signature).Params())
+}
+
+func (_this tsType) Results() []tsgen.Type {
+	return tuple(_this.Underlying().(*types.Signature).Results())
+}
+
+func tuple(t *types.Tuple) []tsgen.Type {
+
+	list := make([]tsgen.Type, t.Len())
+	for i := range list {
+		list[i] = tsType{t.At(i).Type()}
+	}
+
+	return list
+}
+
+func (_this tsType) Variadic() bool {
+	return _this.Underlying().(*types.Signature).Variadic()
+}
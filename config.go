@@ -1,5 +1,7 @@
 package proton
 
+import "io"
+
 type flavor int
 
 const (
@@ -25,6 +27,12 @@ type Config struct {
 	Flavor             flavor
 	Args               []string
 	BrowserBinary      string
+	// Pipe talks to the browser over --remote-debugging-pipe instead of opening
+	// a local debugging port. Not available on windows.
+	Pipe bool
+	// Recorder receives every protocol frame sent and received, one timestamped
+	// JSON Frame per line. Feed it to NewReplayTransport to reproduce a session.
+	Recorder io.Writer
 }
 
 var DefaultBrowserArgs = []string{
@@ -1,13 +1,16 @@
 package proton
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+)
 
 /*
 BrowserClearBrowserCache Clears browser cache.
 */
-func (_this *Browser) BrowserClearBrowserCache() error {
+func (_this *Browser) BrowserClearBrowserCache(ctx context.Context) error {
 
-	_, err := _this.send("Browser.clearBrowserCache", h{})
+	_, err := _this.sendContext(ctx, "Browser.clearBrowserCache", h{})
 
 	return err
 
@@ -16,9 +19,9 @@ func (_this *Browser) BrowserClearBrowserCache() error {
 /*
 BrowserClearBrowserCookies Clears browser cookies.
 */
-func (_this *Browser) BrowserClearBrowserCookies() error {
+func (_this *Browser) BrowserClearBrowserCookies(ctx context.Context) error {
 
-	_, err := _this.send("Browser.clearBrowserCookies", h{})
+	_, err := _this.sendContext(ctx, "Browser.clearBrowserCookies", h{})
 
 	return err
 
@@ -27,9 +30,9 @@ func (_this *Browser) BrowserClearBrowserCookies() error {
 /*
 RuntimeEvaluate Evaluates expression on global object.
 */
-func (_this *Browser) RuntimeEvaluate(Parameters RuntimeEvaluateParameters) (json.RawMessage, error) {
+func (_this *Page) RuntimeEvaluate(ctx context.Context, Parameters RuntimeEvaluateParameters) (json.RawMessage, error) {
 
-	return _this.send("Runtime.evaluate", structToMap(Parameters))
+	return _this.sendContext(ctx, "Runtime.evaluate", structToMap(Parameters))
 }
 
 /* REVISADOS */
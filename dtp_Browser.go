@@ -1,18 +1,21 @@
 package proton
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+)
 
 //BrowserClose Close browser gracefully.
-func (_this *Browser) BrowserClose() error {
+func (_this *Browser) BrowserClose(ctx context.Context) error {
 
-	_, err := _this.send("Browser.close", h{})
+	_, err := _this.sendContext(ctx, "Browser.close", h{})
 
 	return err
 }
 
 //BrowserGetVersion Returns version information.
-func (_this *Browser) BrowserGetVersion() (BrowserGetVersionReturn, error) {
-	result, err := _this.send("Browser.getVersion", h{})
+func (_this *Browser) BrowserGetVersion(ctx context.Context) (BrowserGetVersionReturn, error) {
+	result, err := _this.sendContext(ctx, "Browser.getVersion", h{})
 
 	data := BrowserGetVersionReturn{}
 
@@ -1,22 +1,25 @@
 package proton
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+)
 
 //TODO: Page.addScriptToEvaluateOnNewDocument
 
 //PageBringToFront Brings page to front (activates tab).
-func (_this *Browser) PageBringToFront() error {
+func (_this *Page) PageBringToFront(ctx context.Context) error {
 
-	_, err := _this.send("Page.bringToFront", h{})
+	_, err := _this.sendContext(ctx, "Page.bringToFront", h{})
 
 	return err
 
 }
 
 //PageCaptureScreenshot Capture page screenshot
-func (_this *Browser) PageCaptureScreenshot(Parameters PageCaptureScreenshotParameters) (PageCaptureScreenshotReturn, error) {
+func (_this *Page) PageCaptureScreenshot(ctx context.Context, Parameters PageCaptureScreenshotParameters) (PageCaptureScreenshotReturn, error) {
 
-	result, err := _this.send("Page.captureScreenshot", structToMap(Parameters))
+	result, err := _this.sendContext(ctx, "Page.captureScreenshot", structToMap(Parameters))
 
 	data := PageCaptureScreenshotReturn{}
 
@@ -33,18 +36,18 @@ func (_this *Browser) PageCaptureScreenshot(Parameters PageCaptureScreenshotPara
 //TODO: Page.createIsolatedWorld
 
 //PageDisable Disables page domain notifications.
-func (_this *Browser) PageDisable() error {
+func (_this *Page) PageDisable(ctx context.Context) error {
 
-	_, err := _this.send("Page.disable", h{})
+	_, err := _this.sendContext(ctx, "Page.disable", h{})
 
 	return err
 
 }
 
 //PageEnable Enables page domain notifications.
-func (_this *Browser) PageEnable() error {
+func (_this *Page) PageEnable(ctx context.Context) error {
 
-	_, err := _this.send("Page.enable", h{})
+	_, err := _this.sendContext(ctx, "Page.enable", h{})
 
 	return err
 
@@ -56,17 +59,17 @@ func (_this *Browser) PageEnable() error {
 //TODO: Page.getNavigationHistory
 
 //PageHandleJavaScriptDialog Accepts or dismisses a JavaScript initiated dialog (alert, confirm, prompt, or onbeforeunload).
-func (_this *Browser) PageHandleJavaScriptDialog(Parameters PageHandleJavaScriptDialogParameters) error {
+func (_this *Page) PageHandleJavaScriptDialog(ctx context.Context, Parameters PageHandleJavaScriptDialogParameters) error {
 
-	_, err := _this.send("Page.handleJavaScriptDialog", structToMap(Parameters))
+	_, err := _this.sendContext(ctx, "Page.handleJavaScriptDialog", structToMap(Parameters))
 
 	return err
 }
 
 //PageNavigate Navigates current page to the given URL.
-func (_this *Browser) PageNavigate(Parameters PageNavigateParameters) (PageNavigateReturn, error) {
+func (_this *Page) PageNavigate(ctx context.Context, Parameters PageNavigateParameters) (PageNavigateReturn, error) {
 
-	result, err := _this.send("Page.navigate", structToMap(Parameters))
+	result, err := _this.sendContext(ctx, "Page.navigate", structToMap(Parameters))
 
 	data := PageNavigateReturn{}
 
@@ -82,9 +85,9 @@ func (_this *Browser) PageNavigate(Parameters PageNavigateParameters) (PageNavig
 //TODO: Page.navigateToHistoryEntry
 
 //PagePrintToPDF Print page as PDF.
-func (_this *Browser) PagePrintToPDF(Parameters PrintToPDFParameters) (PrintToPDFReturn, error) {
+func (_this *Page) PagePrintToPDF(ctx context.Context, Parameters PrintToPDFParameters) (PrintToPDFReturn, error) {
 
-	result, err := _this.send("Page.printToPDF", structToMap(Parameters))
+	result, err := _this.sendContext(ctx, "Page.printToPDF", structToMap(Parameters))
 
 	data := PrintToPDFReturn{}
 
@@ -99,45 +102,45 @@ func (_this *Browser) PagePrintToPDF(Parameters PrintToPDFParameters) (PrintToPD
 }
 
 //PageReload Reloads given page optionally ignoring the cache.
-func (_this *Browser) PageReload() error {
+func (_this *Page) PageReload(ctx context.Context) error {
 
-	_, err := _this.send("Page.reload", h{})
+	_, err := _this.sendContext(ctx, "Page.reload", h{})
 
 	return err
 
 }
 
 //Page.removeScriptToEvaluateOnNewDocument Removes given script from the list.
-func (_this *Browser) PageremoveScriptToEvaluateOnNewDocument(Parameters PageremoveScriptToEvaluateOnNewDocumentParameters) error {
+func (_this *Page) PageremoveScriptToEvaluateOnNewDocument(ctx context.Context, Parameters PageremoveScriptToEvaluateOnNewDocumentParameters) error {
 
-	_, err := _this.send("Page.removeScriptToEvaluateOnNewDocument", structToMap(Parameters))
+	_, err := _this.sendContext(ctx, "Page.removeScriptToEvaluateOnNewDocument", structToMap(Parameters))
 
 	return err
 
 }
 
 //PageResetNavigationHistory Resets navigation history for the current page.
-func (_this *Browser) PageResetNavigationHistory() error {
+func (_this *Page) PageResetNavigationHistory(ctx context.Context) error {
 
-	_, err := _this.send("Page.resetNavigationHistory", h{})
+	_, err := _this.sendContext(ctx, "Page.resetNavigationHistory", h{})
 
 	return err
 
 }
 
 //PageSetDocumentContent Sets given markup as the document's HTML.
-func (_this *Browser) PageSetDocumentContent(Parameters PageSetDocumentContentParameters) error {
+func (_this *Page) PageSetDocumentContent(ctx context.Context, Parameters PageSetDocumentContentParameters) error {
 
-	_, err := _this.send("Page.navigate", structToMap(Parameters))
+	_, err := _this.sendContext(ctx, "Page.navigate", structToMap(Parameters))
 
 	return err
 
 }
 
 //PageStopLoading Force the page stop all navigations and pending resource fetches.
-func (_this *Browser) PageStopLoading() error {
+func (_this *Page) PageStopLoading(ctx context.Context) error {
 
-	_, err := _this.send("Page.stopLoading", h{})
+	_, err := _this.sendContext(ctx, "Page.stopLoading", h{})
 
 	return err
 
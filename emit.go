@@ -0,0 +1,91 @@
+package proton
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// protonEvents installs window.proton.on(event, callback), returning a function removing
+// the listener, and window.proton.off(event, callback). Events sent by Emit reach these
+// listeners and are also dispatched on window as a CustomEvent carrying the payload in detail.
+const protonEvents = `(() => {
+	const proton = window.proton = window.proton || {};
+	if (proton.dispatch) {
+		return;
+	}
+	const listeners = new Map();
+	proton.on = (event, callback) => {
+		if (!listeners.has(event)) {
+			listeners.set(event, new Set());
+		}
+		listeners.get(event).add(callback);
+		return () => proton.off(event, callback);
+	};
+	proton.off = (event, callback) => {
+		const set = listeners.get(event);
+		if (set) {
+			set.delete(callback);
+		}
+	};
+	proton.dispatch = (event, payload) => {
+		for (const callback of Array.from(listeners.get(event) || [])) {
+			try {
+				callback(payload);
+			} catch (e) {
+				console.error(e);
+			}
+		}
+		window.dispatchEvent(new CustomEvent(event, {detail: payload}));
+	};
+})();`
+
+// emitScript returns the expression dispatching event with payload in the page.
+// JSON is a valid JS literal, so nothing coming from Go is evaluated as code.
+func emitScript(event string, payload interface{}) (string, error) {
+
+	name, err := json.Marshal(event)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	// The page may be between documents, the event is then dropped
+	return "window.proton && window.proton.dispatch && window.proton.dispatch(" + string(name) + ", " + string(data) + ")", nil
+}
+
+// Emit sends an event to the page. The payload is encoded with encoding/json and handed to
+// the listeners registered with proton.on(event, callback), then dispatched on window as a
+// CustomEvent with the payload in its detail property.
+func (_this *Page) Emit(event string, payload interface{}) error {
+
+	script, err := emitScript(event, payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = _this.RuntimeEvaluate(context.Background(), RuntimeEvaluateParameters{Expression: script})
+
+	return err
+}
+
+// Emit sends an event to every page of the browser, see Page.Emit.
+func (_this *Browser) Emit(event string, payload interface{}) error {
+
+	if _, err := emitScript(event, payload); err != nil {
+		return err
+	}
+
+	var first error
+
+	for _, page := range _this.Pages() {
+		if err := page.Emit(event, payload); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
@@ -0,0 +1,275 @@
+package proton
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// ErrBrowserClosed is returned by every pending and future protocol call once the
+// connection to the browser is lost, the browser process exits or the target of its
+// last page is destroyed.
+var ErrBrowserClosed = errors.New("browser closed")
+
+// ErrPageClosed is returned by the pending and future calls on a page once its target is
+// destroyed or its session detached, while other pages may still be open. It matches
+// ErrBrowserClosed with errors.Is, as the page is gone for its calls either way.
+var ErrPageClosed error = pageClosedError{}
+
+type pageClosedError struct{}
+
+func (pageClosedError) Error() string {
+	return "page closed"
+}
+
+func (pageClosedError) Is(target error) bool {
+	return target == ErrBrowserClosed
+}
+
+// ErrNoContext is returned by Page.Call while the page has no document to run in,
+// e.g. between the start of a navigation and the creation of the new document.
+var ErrNoContext = errors.New("page has no JavaScript context")
+
+// JSON-RPC error codes reported by the DevTools Protocol.
+const (
+	ErrCodeParseError     = -32700
+	ErrCodeInvalidRequest = -32600
+	ErrCodeMethodNotFound = -32601
+	ErrCodeInvalidParams  = -32602
+	ErrCodeInternalError  = -32603
+	// ErrCodeServerError is used by the browser for most command failures,
+	// e.g. "Target closed" or "Cannot find context with specified id".
+	ErrCodeServerError = -32000
+)
+
+// ProtocolError is an error returned by the browser in reply to a protocol command.
+type ProtocolError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data"`
+	Method  string `json:"-"`
+}
+
+func (_this *ProtocolError) Error() string {
+
+	s := fmt.Sprintf("%s: %s (%d)", _this.Method, _this.Message, _this.Code)
+
+	if _this.Data != "" {
+		s += ": " + _this.Data
+	}
+
+	return s
+}
+
+// CallFrame is a JavaScript stack frame. Line and column numbers are 0-based.
+type CallFrame struct {
+	FunctionName string `json:"functionName"`
+	ScriptID     string `json:"scriptId"`
+	URL          string `json:"url"`
+	LineNumber   int    `json:"lineNumber"`
+	ColumnNumber int    `json:"columnNumber"`
+}
+
+// JSException is the error returned when evaluated JavaScript throws or a promise rejects.
+// Line and column numbers are 0-based, as reported by the browser.
+type JSException struct {
+	Text         string      // Exception text, e.g. "Uncaught"
+	Message      string      // Message of the thrown value, e.g. "TypeError: x is undefined"
+	ClassName    string      // Class of the thrown object, e.g. "TypeError"
+	Description  string      // String representation of the thrown value, usually including the JS stack
+	Value        []byte      // Thrown value when it is a primitive, JSON encoded
+	LineNumber   int         // Line of the exception location
+	ColumnNumber int         // Column of the exception location
+	URL          string      // URL of the script, if any
+	ScriptID     string      // Script ID of the exception location
+	StackTrace   []CallFrame // JavaScript stack, innermost frame first
+}
+
+func (_this *JSException) Error() string {
+
+	s := _this.Message
+
+	if s == "" {
+		s = _this.Text
+	}
+
+	if len(_this.StackTrace) > 0 {
+		f := _this.StackTrace[0]
+		s += fmt.Sprintf(" at %s (%s:%d:%d)", f.FunctionName, f.URL, f.LineNumber+1, f.ColumnNumber+1)
+	} else if _this.URL != "" {
+		s += fmt.Sprintf(" at %s:%d:%d", _this.URL, _this.LineNumber+1, _this.ColumnNumber+1)
+	}
+
+	return s
+}
+
+// UnmarshalJSON decodes a Runtime.ExceptionDetails object.
+func (_this *JSException) UnmarshalJSON(b []byte) error {
+
+	details := struct {
+		Text         string `json:"text"`
+		LineNumber   int    `json:"lineNumber"`
+		ColumnNumber int    `json:"columnNumber"`
+		URL          string `json:"url"`
+		ScriptID     string `json:"scriptId"`
+		StackTrace   *struct {
+			CallFrames []CallFrame `json:"callFrames"`
+		} `json:"stackTrace"`
+		Exception *struct {
+			ClassName   string          `json:"className"`
+			Description string          `json:"description"`
+			Value       json.RawMessage `json:"value"`
+		} `json:"exception"`
+	}{}
+
+	if err := json.Unmarshal(b, &details); err != nil {
+		return err
+	}
+
+	*_this = JSException{
+		Text:         details.Text,
+		LineNumber:   details.LineNumber,
+		ColumnNumber: details.ColumnNumber,
+		URL:          details.URL,
+		ScriptID:     details.ScriptID,
+	}
+
+	if details.StackTrace != nil {
+		_this.StackTrace = details.StackTrace.CallFrames
+	}
+
+	if e := details.Exception; e != nil {
+
+		_this.ClassName = e.ClassName
+		_this.Description = e.Description
+		_this.Value = e.Value
+
+		// Error objects carry their stack in the description, keep the first line only
+		_this.Message = strings.SplitN(e.Description, "\n", 2)[0]
+
+		var s string
+		if _this.Message == "" && json.Unmarshal(e.Value, &s) == nil {
+			_this.Message = s
+		} else if _this.Message == "" && len(e.Value) > 0 {
+			_this.Message = string(e.Value)
+		}
+
+	}
+
+	return nil
+}
+
+// JSError can be implemented by errors returned from bound functions to control
+// the code and data of the Error the JS promise is rejected with.
+type JSError interface {
+	error
+	JSCode() string
+	JSData() interface{}
+}
+
+// Codes of the Error a JS promise is rejected with when a bound function fails.
+const (
+	BindingErrorCode = "GO_ERROR" // The function returned an error not implementing JSError
+	BindingPanicCode = "GO_PANIC" // The function panicked
+	// The arguments of the call could not be converted to the parameters of the function
+	BindingArgumentCode = "GO_INVALID_ARGUMENT"
+	// The call came from an origin the binding does not allow
+	BindingOriginCode = "GO_ORIGIN_NOT_ALLOWED"
+)
+
+// BindingPanic is the error reported to JS when a bound function panics.
+type BindingPanic struct {
+	Value interface{} // Value passed to panic
+	Stack []byte      // Goroutine stack at the time of the panic
+}
+
+func (_this *BindingPanic) Error() string {
+	return fmt.Sprintf("panic: %v", _this.Value)
+}
+
+// ArgumentError is the error reported to JS when an argument of a bound function
+// call can't be converted to the Go parameter it is passed to.
+type ArgumentError struct {
+	Index int          // Index of the argument in the JS call
+	Type  reflect.Type // Expected Go type, nil for an argument the function does not take
+	Err   error
+}
+
+func (_this *ArgumentError) Error() string {
+
+	if _this.Type == nil {
+		return fmt.Sprintf("argument %d: %v", _this.Index, _this.Err)
+	}
+
+	return fmt.Sprintf("argument %d: expected %s: %v", _this.Index, _this.Type, _this.Err)
+}
+
+func (_this *ArgumentError) Unwrap() error {
+	return _this.Err
+}
+
+func (_this *ArgumentError) JSCode() string {
+	return BindingArgumentCode
+}
+
+func (_this *ArgumentError) JSData() interface{} {
+
+	data := map[string]interface{}{"index": _this.Index}
+	if _this.Type != nil {
+		data["type"] = _this.Type.String()
+	}
+
+	return data
+}
+
+// OriginError is the error reported to JS when a bound function is called from an
+// origin it does not allow, see Browser.AllowOrigins and Page.AllowOrigins.
+type OriginError struct {
+	Name   string // Name of the binding
+	Origin string // Origin of the calling frame
+}
+
+func (_this *OriginError) Error() string {
+	return fmt.Sprintf("%s can't be called from origin %q", _this.Name, _this.Origin)
+}
+
+func (_this *OriginError) JSCode() string {
+	return BindingOriginCode
+}
+
+func (_this *OriginError) JSData() interface{} {
+	return map[string]interface{}{"origin": _this.Origin}
+}
+
+// bindingError is the JSON form of the Error a binding promise is rejected with.
+type bindingError struct {
+	Name    string      `json:"name"`
+	Message string      `json:"message"`
+	Code    string      `json:"code"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func newBindingError(err error) bindingError {
+
+	e := bindingError{Name: "GoError", Message: err.Error(), Code: BindingErrorCode}
+
+	var jserr JSError
+	var perr *BindingPanic
+
+	if errors.As(err, &jserr) {
+		e.Code = jserr.JSCode()
+		e.Data = jserr.JSData()
+	} else if errors.As(err, &perr) {
+		e.Name = "GoPanic"
+		e.Code = BindingPanicCode
+	}
+
+	// Data that can't be encoded would fail the whole reply
+	if _, merr := json.Marshal(e.Data); merr != nil {
+		e.Data = nil
+	}
+
+	return e
+}
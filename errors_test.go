@@ -0,0 +1,58 @@
+package proton
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestProtocolError(t *testing.T) {
+	res := targetMessage{}
+	err := json.Unmarshal([]byte(`{"id":3,"error":{"code":-32601,"message":"'Foo.bar' wasn't found","data":"x"}}`), &res)
+	if err != nil || res.Error == nil {
+		t.Fatal(err)
+	}
+	res.Error.Method = "Foo.bar"
+
+	var perr *ProtocolError
+	if !errors.As(fmt.Errorf("wrapped: %w", res.Error), &perr) {
+		t.Fatal("errors.As failed")
+	}
+	if perr.Code != ErrCodeMethodNotFound || perr.Data != "x" {
+		t.Fail()
+	}
+	if perr.Error() != "Foo.bar: 'Foo.bar' wasn't found (-32601): x" {
+		t.Error(perr.Error())
+	}
+}
+
+func TestJSException(t *testing.T) {
+	res := targetMessage{}
+	err := json.Unmarshal([]byte(`{"id":4,"result":{
+		"result":{"type":"object","subtype":"error","className":"TypeError"},
+		"exceptionDetails":{"exceptionId":1,"text":"Uncaught","lineNumber":2,"columnNumber":9,"scriptId":"42",
+			"stackTrace":{"callFrames":[{"functionName":"load","scriptId":"42","url":"app.js","lineNumber":2,"columnNumber":9}]},
+			"exception":{"type":"object","subtype":"error","className":"TypeError","description":"TypeError: x is undefined\n    at load (app.js:3:10)"}}}}`), &res)
+	if err != nil || res.Result.Exception == nil {
+		t.Fatal(err)
+	}
+
+	var jerr *JSException
+	if !errors.As(res.Result.Exception, &jerr) {
+		t.Fatal("errors.As failed")
+	}
+	if jerr.Message != "TypeError: x is undefined" || jerr.ClassName != "TypeError" || len(jerr.StackTrace) != 1 {
+		t.Errorf("%+v", jerr)
+	}
+	if jerr.Error() != "TypeError: x is undefined at load (app.js:3:10)" {
+		t.Error(jerr.Error())
+	}
+
+	res = targetMessage{}
+	json.Unmarshal([]byte(`{"id":5,"result":{"result":{"type":"string","value":"boom"},
+		"exceptionDetails":{"text":"Uncaught","exception":{"type":"string","value":"boom"}}}}`), &res)
+	if res.Result.Exception == nil || res.Result.Exception.Error() != "boom" {
+		t.Fail()
+	}
+}
@@ -0,0 +1,264 @@
+package proton
+
+import (
+	"encoding/json"
+	"sync"
+)
+
+// subscriptionSize is the number of events buffered by the channel of Subscribe.
+const subscriptionSize = 16
+
+// eventQueue holds the events between readLoop and the goroutine running the handlers.
+// It grows as needed, so readLoop never waits for a handler and replies are always read,
+// even when a handler waits for one.
+type eventQueue struct {
+	sync.Mutex
+	events []msg
+	closed bool
+	ready  chan struct{}
+}
+
+func newEventQueue() *eventQueue {
+	return &eventQueue{ready: make(chan struct{}, 1)}
+}
+
+func (_this *eventQueue) push(m msg) {
+
+	_this.Lock()
+	_this.events = append(_this.events, m)
+	_this.Unlock()
+
+	_this.signal()
+}
+
+// close ends pop once the queued events are taken.
+func (_this *eventQueue) close() {
+
+	_this.Lock()
+	_this.closed = true
+	_this.Unlock()
+
+	_this.signal()
+}
+
+func (_this *eventQueue) signal() {
+	select {
+	case _this.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pop waits for the next event, it returns false when the queue is closed and empty.
+func (_this *eventQueue) pop() (msg, bool) {
+
+	for {
+		_this.Lock()
+		if len(_this.events) > 0 {
+			m := _this.events[0]
+			_this.events[0] = msg{}
+			_this.events = _this.events[1:]
+			_this.Unlock()
+			return m, true
+		}
+		closed := _this.closed
+		_this.Unlock()
+
+		if closed {
+			return msg{}, false
+		}
+
+		<-_this.ready
+	}
+}
+
+type listener struct {
+	id      int
+	session string
+	handler func(json.RawMessage)
+}
+
+// On registers a handler for a DevTools Protocol event, e.g. "Page.loadEventFired"
+// or "Network.responseReceived", and returns an identifier that can be passed to Off.
+//
+// Handlers run one at a time, in the order the events arrived, on a goroutine
+// separate from the connection reader, so they are free to call protocol methods.
+// A handler that blocks delays every other event.
+func (_this *Browser) On(method string, handler func(params json.RawMessage)) int {
+	return _this.on("", method, handler)
+}
+
+// on registers a handler for the events of a session, or of every session when it is empty.
+func (_this *Browser) on(session string, method string, handler func(json.RawMessage)) int {
+
+	_this.Lock()
+	defer _this.Unlock()
+
+	if _this.listeners == nil {
+		_this.listeners = map[string][]listener{}
+	}
+
+	_this.listenerID++
+	_this.listeners[method] = append(_this.listeners[method], listener{id: _this.listenerID, session: session, handler: handler})
+
+	return _this.listenerID
+}
+
+// Off removes the handler registered by On for the given event.
+func (_this *Browser) Off(method string, id int) {
+
+	_this.Lock()
+	defer _this.Unlock()
+
+	list := _this.listeners[method]
+
+	for i, l := range list {
+		if l.id == id {
+			_this.listeners[method] = append(list[:i:i], list[i+1:]...)
+			break
+		}
+	}
+
+	if len(_this.listeners[method]) == 0 {
+		delete(_this.listeners, method)
+	}
+
+}
+
+// Subscribe returns a channel that receives the parameters of every event with the
+// given method, and a function that cancels the subscription.
+// The channel buffers a few events, events arriving while it is full are dropped so
+// a subscriber that stops reading delays no other handler. The channel is closed on
+// cancel and once the connection to the browser is gone, ending a range over it.
+func (_this *Browser) Subscribe(method string) (<-chan json.RawMessage, func()) {
+	return _this.subscribe("", method, nil)
+}
+
+// subscription is the channel of Subscribe, closed at most once and never while an event is sent on it.
+type subscription struct {
+	sync.Mutex
+	c      chan json.RawMessage
+	closed chan struct{}
+}
+
+func (_this *subscription) send(params json.RawMessage) {
+	_this.Lock()
+	defer _this.Unlock()
+
+	select {
+	case <-_this.closed:
+		return
+	default:
+	}
+
+	select {
+	case _this.c <- params:
+	default:
+	}
+}
+
+func (_this *subscription) close() {
+	_this.Lock()
+	defer _this.Unlock()
+
+	select {
+	case <-_this.closed:
+	default:
+		close(_this.closed)
+		close(_this.c)
+	}
+}
+
+// subscribe subscribes to the events of a session, or of every session when it is empty.
+// The subscription also ends when gone is closed.
+func (_this *Browser) subscribe(session string, method string, gone <-chan struct{}) (<-chan json.RawMessage, func()) {
+
+	sub := &subscription{c: make(chan json.RawMessage, subscriptionSize), closed: make(chan struct{})}
+
+	id := _this.on(session, method, sub.send)
+
+	cancel := func() {
+		_this.Off(method, id)
+		_this.Lock()
+		delete(_this.subscriptions, sub)
+		_this.Unlock()
+		sub.close()
+	}
+
+	_this.Lock()
+	closed := _this.closed
+	if !closed {
+		if _this.subscriptions == nil {
+			_this.subscriptions = map[*subscription]bool{}
+		}
+		_this.subscriptions[sub] = true
+	}
+	_this.Unlock()
+
+	if closed {
+		cancel()
+		return sub.c, cancel
+	}
+
+	if gone != nil {
+		go func() {
+			select {
+			case <-gone:
+				cancel()
+			case <-sub.closed:
+			}
+		}()
+	}
+
+	return sub.c, cancel
+}
+
+// closeSubscriptions closes the channels of the subscriptions, once no more events come.
+func (_this *Browser) closeSubscriptions() {
+
+	_this.Lock()
+	subscriptions := _this.subscriptions
+	_this.subscriptions = nil
+	_this.Unlock()
+
+	for sub := range subscriptions {
+		sub.close()
+	}
+}
+
+// emit queues an event for the handlers registered with On.
+func (_this *Browser) emit(m msg) {
+
+	_this.Lock()
+	_, ok := _this.listeners[m.Method]
+	_this.Unlock()
+
+	if ok {
+		_this.events.push(m)
+	}
+
+}
+
+// eventLoop runs the handlers for queued events until the queue is closed.
+func (_this *Browser) eventLoop(events *eventQueue) {
+
+	for {
+
+		m, ok := events.pop()
+		if !ok {
+			_this.closeSubscriptions()
+			return
+		}
+
+		_this.Lock()
+		list := append([]listener(nil), _this.listeners[m.Method]...)
+		_this.Unlock()
+
+		for _, l := range list {
+			if l.session == "" || l.session == m.SessionID {
+				l.handler(m.Params)
+			}
+		}
+
+	}
+
+}
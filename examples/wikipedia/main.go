@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"github.com/leandroveronezi/proton"
 	"log"
 	"os"
@@ -28,22 +29,25 @@ func main() {
 	}
 
 	defer func() {
-		browser.BrowserClose()
+		browser.BrowserClose(context.Background())
 		//browser.Close()
 	}()
 
-	browser.Bind("Hello", func() string {
+	// Pages of other sites the window navigates to can't call the bindings
+	browser.AllowOrigins("https://www.wikipedia.org")
+
+	browser.Page().Bind("Hello", func() string {
 		return "World!"
 	})
 
-	browser.Bind("Close", func() bool {
-		browser.BrowserClose()
+	browser.Page().Bind("Close", func() bool {
+		browser.BrowserClose(context.Background())
 		return true
 	})
 
-	browser.PageNavigate(proton.PageNavigateParameters{Url: "https://www.wikipedia.org"})
+	browser.Page().PageNavigate(context.Background(), proton.PageNavigateParameters{Url: "https://www.wikipedia.org"})
 
-	sigc := make(chan os.Signal)
+	sigc := make(chan os.Signal, 1)
 	signal.Notify(sigc, os.Interrupt)
 	select {
 	case <-sigc:
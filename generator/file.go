@@ -27,7 +27,10 @@ func (_this goFile) Generate() string {
 
 	aux := `package proton
 
-import "encoding/json"
+import (
+	"context"
+	"encoding/json"
+)
 
 // {DATA} Generated from the latest (tip-of-tree) of protocol {MINOR}.{MAJOR}
 
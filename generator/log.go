@@ -43,7 +43,7 @@ func Log(v ...interface{}) {
 
 	fmt.Print("[" + time.Now().Format("02/01/2006 15:04:05") + "] ")
 	fmt.Println(titulo)
-	fmt.Println(v)
+	fmt.Println(v...)
 	fmt.Println("")
 
 }
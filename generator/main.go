@@ -99,6 +99,19 @@ func main() {
 
 }
 
+// receiverType returns the type that owns the commands of a domain: browser-wide
+// domains live on *Browser, everything else runs on a page session.
+func receiverType(domain string) string {
+
+	switch domain {
+	case "Browser", "Target", "SystemInfo":
+		return "*Browser"
+	}
+
+	return "*Page"
+
+}
+
 func jsonTypeToGOType(tp string) string {
 
 	switch tp {
@@ -276,10 +289,10 @@ func generateFunc(domain string, types []interface{}) (result string) {
 
 		result += lineBreak
 
-		result += "func (_this *Browser) " + ToCamel(domain+"_"+tp.FValue("name").DString("")) + "("
+		result += "func (_this " + receiverType(domain) + ") " + ToCamel(domain+"_"+tp.FValue("name").DString("")) + "(ctx context.Context"
 
 		if len(parameters) > 0 {
-			result += "Parameters " + ToCamel(domain+"_"+tp.FValue("name").DString("")+"_parameters")
+			result += ", Parameters " + ToCamel(domain+"_"+tp.FValue("name").DString("")+"_parameters")
 		}
 
 		result += ")"
@@ -287,7 +300,7 @@ func generateFunc(domain string, types []interface{}) (result string) {
 		if len(returns) > 0 {
 			result += "(" + ToCamel(domain+"_"+tp.FValue("name").DString("")+"_returns") + ",error) {" + lineBreak
 
-			result += tabulation + `result, err := _this.send("` + domain + "." + tp.FValue("name").DString("")
+			result += tabulation + `result, err := _this.sendContext(ctx, "` + domain + "." + tp.FValue("name").DString("")
 
 			if len(parameters) > 0 {
 				result += `", structToMap(Parameters))` + lineBreak
@@ -306,7 +319,7 @@ func generateFunc(domain string, types []interface{}) (result string) {
 		} else {
 			result += " error {" + lineBreak
 
-			result += tabulation + `_, err := _this.send("` + domain + "." + tp.FValue("name").DString("")
+			result += tabulation + `_, err := _this.sendContext(ctx, "` + domain + "." + tp.FValue("name").DString("")
 
 			if len(parameters) > 0 {
 				result += `", structToMap(Parameters))` + lineBreak
@@ -1,12 +1,17 @@
 package proton
 
 import (
+	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
-	"golang.org/x/net/websocket"
 	"io/ioutil"
+	"net/http"
 	"os/exec"
 	"regexp"
+	"runtime"
+	"strings"
+	"time"
 )
 
 func (_this *Browser) Run(conf ...Config) error {
@@ -78,8 +83,12 @@ func (_this *Browser) Run(conf ...Config) error {
 		args = append(args, "--silent-launch")
 	}
 
-	args = append(args, "--remote-debugging-port=0")
-	args = append(args, "--remote-allow-origins=*")
+	if _this.config.Pipe {
+		args = append(args, "--remote-debugging-pipe")
+	} else {
+		args = append(args, "--remote-debugging-port=0")
+		args = append(args, "--remote-allow-origins=*")
+	}
 		
 	_this.config.Args = args
 
@@ -87,12 +96,110 @@ func (_this *Browser) Run(conf ...Config) error {
 
 }
 
-func (_this *Browser) makeBrowser() error {
+// Connect attaches to an already running browser through its DevTools websocket URL,
+// e.g. ws://127.0.0.1:9222/devtools/browser/<id>, instead of launching one.
+func (_this *Browser) Connect(wsURL string, conf ...Config) error {
+
+	if len(conf) > 0 {
+		_this.config = conf[0]
+	}
+
+	_this.reset()
+	_this.cmd = nil
+
+	conn, err := dialWebsocket(wsURL)
+	if err != nil {
+		return err
+	}
+
+	return _this.start(conn)
+
+}
+
+// ConnectTransport attaches to a browser reachable through the given transport.
+func (_this *Browser) ConnectTransport(conn Transport, conf ...Config) error {
+
+	if len(conf) > 0 {
+		_this.config = conf[0]
+	}
+
+	_this.reset()
+	_this.cmd = nil
+
+	return _this.start(conn)
+
+}
+
+// endpointTimeout bounds the lookup of the websocket URL by ConnectHTTP.
+const endpointTimeout = 10 * time.Second
+
+// ConnectHTTP attaches to an already running browser through its remote debugging
+// HTTP endpoint, e.g. http://127.0.0.1:9222, looking up the websocket URL at /json/version.
+// The lookup fails when the endpoint does not answer within 10 seconds.
+func (_this *Browser) ConnectHTTP(endpoint string, conf ...Config) error {
+
+	ctx, cancel := context.WithTimeout(context.Background(), endpointTimeout)
+	defer cancel()
+
+	return _this.ConnectHTTPContext(ctx, endpoint, conf...)
+}
+
+// ConnectHTTPContext is like ConnectHTTP, but the lookup stops when the context is done instead.
+func (_this *Browser) ConnectHTTPContext(ctx context.Context, endpoint string, conf ...Config) error {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(endpoint, "/")+"/json/version", nil)
+	if err != nil {
+		return err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s/json/version: %s", endpoint, resp.Status)
+	}
+
+	version := struct {
+		WebSocketDebuggerURL string `json:"webSocketDebuggerUrl"`
+	}{}
+
+	if err := json.NewDecoder(resp.Body).Decode(&version); err != nil {
+		return err
+	}
+
+	if version.WebSocketDebuggerURL == "" {
+		return errors.New("Debugger URL not found")
+	}
+
+	return _this.Connect(version.WebSocketDebuggerURL, conf...)
+
+}
+
+// reset clears the state left by a previous connection.
+func (_this *Browser) reset() {
 
 	// The first two IDs are used internally during the initialization
 	_this.id = 2
-	_this.pending = map[int]chan result{}
-	_this.bindings = map[string]bindingFunc{}
+	_this.pending = map[int]pendingCall{}
+	_this.closed = false
+	_this.outgoing = make(chan outboundFrame, outgoingQueueSize)
+	_this.stopped = make(chan struct{})
+	_this.conn = nil
+	_this.page = nil
+	_this.pages = map[string]*Page{}
+
+}
+
+func (_this *Browser) makeBrowser() error {
+
+	_this.reset()
+
+	if _this.config.Pipe {
+		return _this.makeBrowserPipe()
+	}
 
 	// Start chrome process
 	_this.cmd = exec.Command(_this.config.BrowserBinary, _this.config.Args...)
@@ -111,67 +218,89 @@ func (_this *Browser) makeBrowser() error {
 		_this.kill(false)
 		return err
 	}
-	wsURL := m[1]
 
-	// Open a websocket
-	_this.ws, err = websocket.Dial(wsURL, "", "http://127.0.0.1")
+	conn, err := dialWebsocket(m[1])
 	if err != nil {
 		_this.kill(false)
 		return err
 	}
 
-	// Find target and initialize session
-	_this.target, err = _this.findTarget()
-	if err != nil {
-		_this.kill(false)
-		return err
+	return _this.start(conn)
+
+}
+
+// makeBrowserPipe starts chrome with --remote-debugging-pipe, handing it the read
+// end of one pipe as file descriptor 3 and the write end of another as descriptor 4.
+func (_this *Browser) makeBrowserPipe() error {
+
+	if runtime.GOOS == "windows" {
+		return errors.New("Remote debugging pipe is not supported on windows")
 	}
 
-	_this.session, err = _this.startSession(_this.target)
+	_this.cmd = exec.Command(_this.config.BrowserBinary, _this.config.Args...)
+
+	conn, err := startPipe(_this.cmd)
 	if err != nil {
-		_this.kill(false)
 		return err
 	}
 
-	go _this.readLoop()
+	return _this.start(conn)
 
-	for method, args := range map[string]h{
-		"Page.enable":          nil,
-		"Target.setAutoAttach": {"autoAttach": true, "waitForDebuggerOnStart": false},
-		"Network.enable":       nil,
-		"Runtime.enable":       nil,
-		"Security.enable":      nil,
-		"Performance.enable":   nil,
-		"Log.enable":           nil,
-	} {
+}
 
-		if _, err := _this.send(method, args); err != nil {
-			_this.kill(false)
-			_this.cmd.Wait()
-			return err
-		}
+// start attaches to the first page over conn and starts the read loop.
+func (_this *Browser) start(conn Transport) error {
 
+	if _this.config.Recorder != nil {
+		conn = &recordingTransport{Transport: conn, w: _this.config.Recorder}
 	}
 
-	if !contains(_this.config.Args, "--headless") {
-		win, err := _this.getWindowForTarget(_this.target)
-		if err != nil {
-			_this.kill(false)
-			return err
-		}
-		_this.window = win.WindowID
+	_this.conn = conn
+
+	// Find target and initialize session
+	target, err := _this.findTarget()
+	if err != nil {
+		_this.kill(false)
+		return err
 	}
 
-	done := make(chan struct{})
+	session, err := _this.startSession(target)
 	if err != nil {
+		_this.kill(false)
 		return err
 	}
 
+	_this.page = _this.addPage(target, session)
+
+	done := make(chan struct{})
+
+	_this.events = newEventQueue()
+	go _this.eventLoop(_this.events)
+	go _this.writeLoop(_this.conn, _this.outgoing, _this.stopped)
 	go func() {
-		_this.cmd.Wait()
-		close(done)
+		_this.readLoop()
+		// Without a process to wait for, the browser is gone with the connection
+		if _this.cmd == nil {
+			close(done)
+		}
 	}()
 
+	if err := _this.page.init(); err != nil {
+		_this.kill(false)
+		if _this.cmd != nil {
+			_this.cmd.Wait()
+		}
+		return err
+	}
+
+	if _this.cmd != nil {
+		go func() {
+			_this.cmd.Wait()
+			_this.failPending()
+			close(done)
+		}()
+	}
+
 	_this.done = done
 
 	return nil
@@ -0,0 +1,265 @@
+// Package tsgen writes TypeScript declarations for bound Go functions. It is shared by
+// Page.TypeScriptDefinitions, which reads the Go types with reflect, and the proton-dts
+// command, which reads them from source.
+package tsgen
+
+import (
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Field is a property of a TypeScript interface.
+type Field struct {
+	Name     string
+	Type     string
+	Optional bool
+}
+
+// Interface is a TypeScript interface generated for a named Go struct.
+type Interface struct {
+	Name   string
+	Fields []Field
+}
+
+// Definitions collects the bound functions and the interfaces they reference.
+type Definitions struct {
+	Members    map[string]string // Binding name, possibly dotted, to its method signature
+	Interfaces []Interface
+}
+
+var identifier = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*$`)
+
+// Key returns name as a TypeScript property key, quoted when it is not an identifier.
+func Key(name string) string {
+
+	if identifier.MatchString(name) {
+		return name
+	}
+
+	return `"` + strings.ReplaceAll(name, `"`, `\"`) + `"`
+}
+
+// Nullable returns the type of a Go pointer to t.
+func Nullable(t string) string {
+	return t + " | null"
+}
+
+// Array returns the type of a Go slice or array of t.
+func Array(t string) string {
+
+	if strings.Contains(t, " ") {
+		return "(" + t + ")[]"
+	}
+
+	return t + "[]"
+}
+
+// Record returns the type of a Go map with values of type t.
+func Record(t string) string {
+	return "Record<string, " + t + ">"
+}
+
+// Object returns an inline object type for an anonymous Go struct.
+func Object(fields []Field) string {
+
+	members := []string{}
+
+	for _, f := range fields {
+		members = append(members, field(f))
+	}
+
+	return "{ " + strings.Join(members, "; ") + " }"
+}
+
+// Types of the binary parameters and results of bound functions.
+const (
+	BinaryParam  = "BufferSource | Blob"          // []byte parameter
+	ReaderParam  = "BufferSource | Blob | string" // io.Reader parameter
+	BinaryResult = "Uint8Array"                   // []byte or io.Reader result
+)
+
+// Param is a parameter of a bound function.
+type Param struct {
+	Type     string
+	Optional bool // Trailing parameter the caller may leave out
+	Rest     bool // Variadic parameter, Type is the type of one element
+}
+
+// Optional marks the trailing nullable parameters as optional, they are nil when left out.
+func Optional(params []Param) {
+
+	i := len(params) - 1
+	if i >= 0 && params[i].Rest {
+		i--
+	}
+
+	for ; i >= 0 && strings.HasSuffix(params[i].Type, " | null"); i-- {
+		params[i].Optional = true
+	}
+}
+
+// Signature returns the method signature of a bound function returning returns.
+// A function taking a context.Context also accepts the AbortSignal cancelling it,
+// unless it is variadic as nothing may follow a rest parameter.
+func Signature(params []Param, abortable bool, returns string) string {
+
+	args := []string{}
+	rest := false
+
+	for i, p := range params {
+
+		name := "arg" + strconv.Itoa(i)
+
+		switch {
+		case p.Rest:
+			rest = true
+			args = append(args, "..."+name+": "+Array(p.Type))
+		case p.Optional:
+			args = append(args, name+"?: "+p.Type)
+		default:
+			args = append(args, name+": "+p.Type)
+		}
+	}
+
+	if abortable && !rest {
+		args = append(args, "signal?: AbortSignal")
+	}
+
+	return "(" + strings.Join(args, ", ") + "): " + returns
+}
+
+// Promise returns the type returned by a bound function with the result t, void when t is empty.
+func Promise(t string) string {
+
+	if t == "" {
+		t = "void"
+	}
+
+	return "Promise<" + t + ">"
+}
+
+// AsyncIterable returns the type returned by a bound function streaming items of type t.
+func AsyncIterable(t string) string {
+	return "AsyncIterable<" + t + ">"
+}
+
+// JSONField parses the json tag of a struct field, returning the property name,
+// whether it is optional and whether the field is skipped. encoded reports the
+// ",string" option, which turns numbers and booleans into strings.
+func JSONField(goName string, tag string) (name string, optional bool, skip bool, encoded bool) {
+
+	if tag == "-" {
+		return "", false, true, false
+	}
+
+	parts := strings.Split(tag, ",")
+
+	name = parts[0]
+	if name == "" {
+		name = goName
+	}
+
+	for _, opt := range parts[1:] {
+		switch opt {
+		case "omitempty":
+			optional = true
+		case "string":
+			encoded = true
+		}
+	}
+
+	return name, optional, false, encoded
+}
+
+func field(f Field) string {
+
+	if f.Optional {
+		return Key(f.Name) + "?: " + f.Type
+	}
+
+	return Key(f.Name) + ": " + f.Type
+}
+
+// namespace is a node of the window object, dotted binding names nest inside it.
+type namespace struct {
+	members  map[string]string
+	children map[string]*namespace
+}
+
+func (_this *namespace) add(path []string, signature string) {
+
+	if len(path) == 1 {
+		_this.members[path[0]] = signature
+		return
+	}
+
+	child, ok := _this.children[path[0]]
+	if !ok {
+		child = &namespace{members: map[string]string{}, children: map[string]*namespace{}}
+		_this.children[path[0]] = child
+	}
+
+	child.add(path[1:], signature)
+}
+
+func (_this *namespace) write(b *strings.Builder, indent string) {
+
+	names := []string{}
+	for name := range _this.members {
+		names = append(names, name)
+	}
+	for name := range _this.children {
+		if _, ok := _this.members[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+
+		if signature, ok := _this.members[name]; ok {
+			b.WriteString(indent + Key(name) + signature + ";\n")
+			continue
+		}
+
+		b.WriteString(indent + Key(name) + ": {\n")
+		_this.children[name].write(b, indent+"\t")
+		b.WriteString(indent + "};\n")
+	}
+}
+
+// String returns the content of the .d.ts file.
+func (_this Definitions) String() string {
+
+	b := &strings.Builder{}
+
+	b.WriteString("// Code generated by proton. DO NOT EDIT.\n\nexport {};\n")
+
+	interfaces := append([]Interface(nil), _this.Interfaces...)
+	sort.Slice(interfaces, func(i, j int) bool { return interfaces[i].Name < interfaces[j].Name })
+
+	for _, i := range interfaces {
+
+		b.WriteString("\nexport interface " + i.Name + " {\n")
+
+		for _, f := range i.Fields {
+			b.WriteString("\t" + field(f) + ";\n")
+		}
+
+		b.WriteString("}\n")
+	}
+
+	root := &namespace{members: map[string]string{}, children: map[string]*namespace{}}
+
+	for name, signature := range _this.Members {
+		root.add(strings.Split(name, "."), signature)
+	}
+
+	b.WriteString("\ndeclare global {\n\tinterface Window {\n")
+	root.write(b, "\t\t")
+	b.WriteString("\t}\n}\n")
+
+	return b.String()
+}
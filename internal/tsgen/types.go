@@ -0,0 +1,294 @@
+package tsgen
+
+import (
+	"path"
+	"sort"
+	"strings"
+)
+
+// Kind is the kind of a Go type, as far as its declaration is concerned.
+type Kind int
+
+const (
+	KindOther Kind = iota // Interfaces and anything else, declared as any
+	KindBool
+	KindNumber
+	KindByte // uint8, a number on its own, binary data in a slice
+	KindString
+	KindPointer
+	KindSlice
+	KindArray
+	KindMap
+	KindStruct
+	KindFunc
+	KindChan
+)
+
+// Type describes a Go type. Page.TypeScriptDefinitions reads it with reflect, the
+// proton-dts command with go/types, and both declare it with the same rules.
+type Type interface {
+	Kind() Kind
+	// Named returns the package path and the name of a named type, empty for an unnamed
+	// one. The package path of a predeclared type such as error is empty.
+	Named() (pkg string, name string)
+	// ID identifies the type, so a named struct is declared once.
+	ID() interface{}
+	// HasMethod reports whether the method set of the type, or with pointer that of
+	// a pointer to it, has the exported method.
+	HasMethod(name string, pointer bool) bool
+	// Elem returns the element type of a pointer, slice, array, map or channel.
+	Elem() Type
+	// Recv reports whether a channel can be received from.
+	Recv() bool
+	Fields() []StructField
+	Params() []Type
+	Results() []Type
+	Variadic() bool
+}
+
+// StructField is a field of a struct Type.
+type StructField struct {
+	Name     string
+	Tag      string // json tag
+	Embedded bool
+	Exported bool
+	Type     Type
+}
+
+func is(t Type, pkg string, name string) bool {
+	p, n := t.Named()
+	return p == pkg && n == name
+}
+
+// IsBinary reports whether values of type t are binary data: slices of bytes, which
+// encoding/json would send as base64, unless they have their own JSON or text encoding
+// like json.RawMessage.
+func IsBinary(t Type) bool {
+
+	if t.Kind() != KindSlice || t.Elem().Kind() != KindByte {
+		return false
+	}
+
+	for _, m := range []string{"MarshalJSON", "UnmarshalJSON", "MarshalText", "UnmarshalText"} {
+		if t.HasMethod(m, true) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// IsStreamCallback reports whether a parameter of type t is a func(T) receiving the items of a stream.
+func IsStreamCallback(t Type) bool {
+	return t.Kind() == KindFunc && len(t.Params()) == 1 && len(t.Results()) == 0
+}
+
+// Declare declares the bound functions, keyed by their possibly dotted name, along with
+// interfaces for the named structs they use.
+func Declare(bindings map[string]Type) Definitions {
+
+	names := []string{}
+	for name := range bindings {
+		names = append(names, name)
+	}
+	// Sorted, so the output and the names of colliding interfaces are stable
+	sort.Strings(names)
+
+	types := &declarations{names: map[interface{}]string{}, used: map[string]bool{}}
+	members := map[string]string{}
+
+	for _, name := range names {
+		members[name] = types.signature(bindings[name])
+	}
+
+	return Definitions{Members: members, Interfaces: types.interfaces}
+}
+
+// declarations converts Go types to TypeScript the way encoding/json encodes them,
+// declaring an interface for every named struct it meets.
+type declarations struct {
+	names      map[interface{}]string
+	used       map[string]bool
+	interfaces []Interface
+}
+
+func (_this *declarations) typeOf(t Type) string {
+
+	switch {
+	case t.Kind() == KindPointer:
+		// Checked first, a nil pointer is null whatever the encoding of its element
+		return Nullable(_this.typeOf(t.Elem()))
+	case is(t, "time", "Time"):
+		return "string"
+	case t.HasMethod("MarshalJSON", true):
+		return "any"
+	case t.HasMethod("MarshalText", true):
+		return "string"
+	}
+
+	switch t.Kind() {
+	case KindBool:
+		return "boolean"
+	case KindNumber, KindByte:
+		return "number"
+	case KindString:
+		return "string"
+	case KindSlice:
+		if t.Elem().Kind() == KindByte {
+			// Encoded as a base64 string, unlike byte arrays
+			return "string"
+		}
+		return Nullable(Array(_this.typeOf(t.Elem())))
+	case KindArray:
+		return Array(_this.typeOf(t.Elem()))
+	case KindMap:
+		return Nullable(Record(_this.typeOf(t.Elem())))
+	case KindStruct:
+		if _, name := t.Named(); name != "" {
+			return _this.named(t)
+		}
+		return Object(_this.fields(t))
+	}
+
+	return "any"
+}
+
+// named returns the interface declared for a named struct, declaring it on first use.
+func (_this *declarations) named(t Type) string {
+
+	if name, ok := _this.names[t.ID()]; ok {
+		return name
+	}
+
+	pkg, name := t.Named()
+	if _this.used[name] {
+		// Same name in another package, prefix it with the package name
+		base := path.Base(pkg)
+		name = strings.ToUpper(base[:1]) + base[1:] + name
+	}
+
+	_this.used[name] = true
+	// Registered before the fields, so recursive types refer to themselves
+	_this.names[t.ID()] = name
+
+	i := len(_this.interfaces)
+	_this.interfaces = append(_this.interfaces, Interface{Name: name})
+	_this.interfaces[i].Fields = _this.fields(t)
+
+	return name
+}
+
+func (_this *declarations) fields(t Type) []Field {
+
+	fields := []Field{}
+
+	for _, f := range t.Fields() {
+
+		if f.Embedded && f.Tag == "" {
+			ft := f.Type
+			if ft.Kind() == KindPointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == KindStruct {
+				// Fields of embedded structs are promoted
+				fields = append(fields, _this.fields(ft)...)
+				continue
+			}
+		}
+
+		if !f.Exported {
+			continue
+		}
+
+		name, optional, skip, encoded := JSONField(f.Name, f.Tag)
+		if skip {
+			continue
+		}
+
+		tp := _this.typeOf(f.Type)
+		if encoded {
+			tp = "string"
+		}
+
+		fields = append(fields, Field{Name: name, Type: tp, Optional: optional})
+	}
+
+	return fields
+}
+
+// signature returns the method signature of a bound function of type t.
+func (_this *declarations) signature(t Type) string {
+
+	params := []Param{}
+	abortable := false
+	var item Type
+
+	in := t.Params()
+
+	for i, p := range in {
+
+		if i == 0 && is(p, "context", "Context") {
+			abortable = true
+			continue
+		}
+
+		if IsStreamCallback(p) {
+			if item == nil {
+				item = p.Params()[0]
+			}
+			continue
+		}
+
+		if t.Variadic() && i == len(in)-1 {
+			params = append(params, Param{Type: _this.typeOf(p.Elem()), Rest: true})
+			continue
+		}
+
+		params = append(params, Param{Type: _this.paramType(p)})
+	}
+
+	Optional(params)
+
+	out := t.Results()
+
+	if item == nil && len(out) > 0 && out[0].Kind() == KindChan && out[0].Recv() {
+		item = out[0].Elem()
+	}
+
+	if item != nil {
+		return Signature(params, abortable, AsyncIterable(_this.resultType(item)))
+	}
+
+	result := ""
+	if len(out) > 0 && !is(out[0], "", "error") {
+		result = _this.resultType(out[0])
+	}
+
+	return Signature(params, abortable, Promise(result))
+}
+
+// paramType is typeOf for a parameter, which also takes binary data.
+func (_this *declarations) paramType(t Type) string {
+
+	switch {
+	case is(t, "io", "Reader"):
+		return ReaderParam
+	case IsBinary(t):
+		return BinaryParam
+	}
+
+	return _this.typeOf(t)
+}
+
+// resultType is typeOf for a result, binary results arrive as a Uint8Array.
+func (_this *declarations) resultType(t Type) string {
+
+	switch {
+	case t.HasMethod("Read", false):
+		return BinaryResult
+	case IsBinary(t):
+		return Nullable(BinaryResult)
+	}
+
+	return _this.typeOf(t)
+}
@@ -0,0 +1,42 @@
+package proton
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// BindingCall is a call of a bound function from the page, as seen by binding middleware.
+type BindingCall struct {
+	Page      *Page
+	Name      string            // Name the function is bound as
+	Args      []json.RawMessage // Arguments of the call, a middleware may replace them
+	ContextID int               // Execution context the call comes from
+	FrameID   string            // Frame the call comes from
+	Origin    string            // Origin of the frame, e.g. "https://example.com"
+}
+
+// BindingHandler runs a binding call, returning its result or the error rejecting the JS promise.
+type BindingHandler func(ctx context.Context, call *BindingCall) (interface{}, error)
+
+// UseBindingMiddleware wraps every call of a bound function, on every page, with middleware.
+// A middleware calls next to go on with the call, or returns an error without calling it
+// to reject the JS promise, e.g. to deny a call or limit its rate. Middleware run in the
+// order they are added, the first added sees the call first.
+func (_this *Browser) UseBindingMiddleware(middleware func(next BindingHandler) BindingHandler) {
+	_this.Lock()
+	defer _this.Unlock()
+
+	_this.middleware = append(_this.middleware, middleware)
+}
+
+// bindingHandler wraps the handler of a binding with the middleware added so far.
+func (_this *Browser) bindingHandler(handler BindingHandler) BindingHandler {
+	_this.Lock()
+	defer _this.Unlock()
+
+	for i := len(_this.middleware) - 1; i >= 0; i-- {
+		handler = _this.middleware[i](handler)
+	}
+
+	return handler
+}
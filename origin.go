@@ -0,0 +1,92 @@
+package proton
+
+import (
+	"strings"
+)
+
+// AllowOrigins restricts the bound functions of every page to calls from the given origins,
+// e.g. "https://example.com" or "file://". Calls from any other frame are rejected with an
+// *OriginError before binding middleware run. A list set with Page.AllowOrigins takes
+// precedence for its bindings. Without origins, calls are allowed from anywhere again.
+func (_this *Browser) AllowOrigins(origins ...string) {
+	_this.Lock()
+	defer _this.Unlock()
+
+	_this.origins = normalizeOrigins(origins)
+}
+
+// AllowOrigins restricts the function bound as name, or every method bound under name by
+// BindObject, to calls from the given origins, instead of those allowed by Browser.AllowOrigins.
+// The list also applies to functions bound later, so it can be set before Bind. Without
+// origins, the binding falls back to the list of the browser.
+func (_this *Page) AllowOrigins(name string, origins ...string) {
+	_this.Lock()
+	defer _this.Unlock()
+
+	if len(origins) == 0 {
+		delete(_this.origins, name)
+		return
+	}
+
+	_this.origins[name] = normalizeOrigins(origins)
+}
+
+// checkOrigin returns an *OriginError when the origin of the call is not allowed to call the binding.
+func (_this *Page) checkOrigin(call *BindingCall) error {
+
+	_this.Lock()
+	origins, ok := _this.bindingOrigins(call.Name)
+	_this.Unlock()
+
+	if !ok {
+		_this.browser.Lock()
+		origins = _this.browser.origins
+		_this.browser.Unlock()
+	}
+
+	if origins == nil {
+		return nil
+	}
+
+	origin := strings.TrimSuffix(call.Origin, "/")
+
+	for _, allowed := range origins {
+		if allowed == "*" || allowed == origin {
+			return nil
+		}
+	}
+
+	return &OriginError{Name: call.Name, Origin: call.Origin}
+}
+
+// bindingOrigins returns the list set for name, or for the object its method is bound under.
+func (_this *Page) bindingOrigins(name string) ([]string, bool) {
+
+	for {
+		if origins, ok := _this.origins[name]; ok {
+			return origins, true
+		}
+
+		i := strings.LastIndex(name, ".")
+		if i < 0 {
+			return nil, false
+		}
+		name = name[:i]
+	}
+}
+
+// normalizeOrigins drops trailing slashes, so "https://example.com/" matches the origin
+// the browser reports. An empty list gives nil, allowing every origin.
+func normalizeOrigins(origins []string) []string {
+
+	if len(origins) == 0 {
+		return nil
+	}
+
+	normalized := make([]string, len(origins))
+	for i, origin := range origins {
+		normalized[i] = strings.TrimSuffix(origin, "/")
+	}
+
+	return normalized
+}
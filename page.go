@@ -0,0 +1,1100 @@
+package proton
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"reflect"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// bindingReply settles the promise returned by a binding stub, it is called with
+// the binding name, the call sequence number, the result and the error.
+// The promise may already be settled when the call was aborted from JS, or the binding removed.
+// Errors arrive as {name, message, code, data} and are rejected as JS Error objects,
+// binary results as {$binary} and are resolved as a Uint8Array.
+const bindingReply = `function(name, seq, result, error) {
+	const me = window[name];
+	if (!me) {
+		return;
+	}
+	const callback = error ? me['errors'].get(seq) : me['callbacks'].get(seq);
+	me['callbacks'].delete(seq);
+	me['errors'].delete(seq);
+	me['streams'].delete(seq);
+	me['blobs'].delete(seq);
+	if (!callback) {
+		return;
+	}
+	if (error) {
+		const e = new Error(error.message);
+		e.name = error.name;
+		e.code = error.code;
+		if ('data' in error) {
+			e.data = error.data;
+		}
+		callback(e);
+	} else {
+		callback(window.proton.binary.decode(result));
+	}
+}`
+
+// bindingStream queues an item for the async iterator returned by a streaming binding stub,
+// it is called with the binding name, the call sequence number and the item.
+const bindingStream = `function(name, seq, item) {
+	const streams = window[name] && window[name]['streams'];
+	const push = streams && streams.get(seq);
+	if (push) {
+		push(window.proton.binary.decode(item));
+	}
+}`
+
+// bindingRemove deletes the stub of a binding from the page, it is called with the
+// binding name. Calls still in progress are rejected, and namespaces left empty by
+// the removal of a dotted name are deleted too.
+const bindingRemove = `function(name) {
+	const me = window[name];
+	if (me) {
+		if (me['errors']) {
+			for (const reject of me['errors'].values()) {
+				reject(new Error(name + ' was unbound'));
+			}
+		}
+		delete window[name];
+	}
+	const path = name.split('.');
+	if (path.length < 2) {
+		return;
+	}
+	const parents = [window];
+	for (const key of path.slice(0, -1)) {
+		const next = parents[parents.length - 1][key];
+		if (!next) {
+			return;
+		}
+		parents.push(next);
+	}
+	delete parents[parents.length - 1][path[path.length - 1]];
+	for (let i = parents.length - 1; i > 0 && Object.keys(parents[i]).length === 0; i--) {
+		delete parents[i - 1][path[i - 1]];
+	}
+}`
+
+// callKey identifies a binding call in progress.
+type callKey struct {
+	context int
+	name    string
+	seq     int
+}
+
+// Page is a browser target (a window or tab) driven through its own session.
+type Page struct {
+	browser *Browser
+	target  string
+	session string
+	window  int
+	context int // default execution context of the main frame
+	done    chan struct{}
+	sync.Mutex
+	bindings   map[string]bindingFunc
+	signatures map[string]reflect.Type
+	scripts    map[string]string // Identifier of the stub script of each binding
+	contexts   map[int]executionContext
+	calls      map[callKey]context.CancelFunc
+	origins    map[string][]string // Origins allowed to call a binding, by name or object
+}
+
+func newPage(browser *Browser, target string, session string) *Page {
+	return &Page{
+		browser:    browser,
+		target:     target,
+		session:    session,
+		done:       make(chan struct{}),
+		bindings:   map[string]bindingFunc{},
+		signatures: map[string]reflect.Type{},
+		scripts:    map[string]string{},
+		contexts:   map[int]executionContext{},
+		calls:      map[callKey]context.CancelFunc{},
+		origins:    map[string][]string{},
+	}
+}
+
+// close marks the page as closed, cancelling the binding calls in progress.
+func (_this *Page) close() {
+	close(_this.done)
+	_this.cancelCalls(func(callKey) bool { return true })
+}
+
+// cancelCalls cancels the context of the binding calls in progress matching the filter.
+func (_this *Page) cancelCalls(match func(callKey) bool) {
+	_this.Lock()
+	defer _this.Unlock()
+
+	for key, cancel := range _this.calls {
+		if match(key) {
+			cancel()
+		}
+	}
+}
+
+// init enables the domains proton relies on, installs the proton.on helper
+// and looks up the window of the page.
+func (_this *Page) init() error {
+
+	for method, args := range map[string]h{
+		"Page.enable":          nil,
+		"Target.setAutoAttach": {"autoAttach": true, "waitForDebuggerOnStart": false, "flatten": true},
+		"Network.enable":       nil,
+		"Runtime.enable":       nil,
+		"Security.enable":      nil,
+		"Performance.enable":   nil,
+		"Log.enable":           nil,
+	} {
+
+		if _, err := _this.send(method, args); err != nil {
+			return err
+		}
+
+	}
+
+	for _, script := range []string{protonEvents, protonBinary} {
+		if _, err := _this.addScript(script); err != nil {
+			return err
+		}
+	}
+
+	if !contains(_this.browser.config.Args, "--headless") {
+		win, err := _this.browser.getWindowForTarget(_this.target)
+		// The args of a browser proton attached to are unknown, it may be headless and
+		// have no window. Without one, window stays 0 and the bounds can't be changed.
+		if err != nil && _this.browser.cmd != nil {
+			return err
+		}
+		_this.window = win.WindowID
+	}
+
+	return nil
+}
+
+func (_this *Page) send(method string, params h) (json.RawMessage, error) {
+	return _this.sendContext(context.Background(), method, params)
+}
+
+func (_this *Page) sendContext(ctx context.Context, method string, params h) (json.RawMessage, error) {
+
+	select {
+	case <-_this.done:
+		_this.browser.Lock()
+		closed := _this.browser.closed
+		_this.browser.Unlock()
+		// Pages are closed along with the browser
+		if closed {
+			return nil, ErrBrowserClosed
+		}
+		return nil, ErrPageClosed
+	default:
+	}
+
+	return _this.browser.call(ctx, _this.session, method, params)
+}
+
+// executionContext is where the JS of a frame runs, a binding call comes from one.
+type executionContext struct {
+	Origin  string
+	FrameID string
+}
+
+// contextCreated records an execution context of the page, and the default execution
+// context of the main frame, used by Call.
+func (_this *Page) contextCreated(params json.RawMessage) {
+
+	event := struct {
+		Context struct {
+			ID      int    `json:"id"`
+			Origin  string `json:"origin"`
+			AuxData struct {
+				IsDefault bool   `json:"isDefault"`
+				FrameID   string `json:"frameId"`
+			} `json:"auxData"`
+		} `json:"context"`
+	}{}
+	json.Unmarshal(params, &event)
+
+	_this.Lock()
+	defer _this.Unlock()
+
+	_this.contexts[event.Context.ID] = executionContext{Origin: event.Context.Origin, FrameID: event.Context.AuxData.FrameID}
+
+	// The main frame shares its identifier with the target
+	if event.Context.AuxData.IsDefault && event.Context.AuxData.FrameID == _this.target {
+		_this.context = event.Context.ID
+	}
+}
+
+// contextDestroyed forgets an execution context when it is destroyed, or every one when id is 0.
+func (_this *Page) contextDestroyed(id int) {
+	_this.Lock()
+	defer _this.Unlock()
+
+	if id == 0 {
+		_this.contexts = map[int]executionContext{}
+	} else {
+		delete(_this.contexts, id)
+	}
+
+	if id == 0 || id == _this.context {
+		_this.context = 0
+	}
+}
+
+// bindingCalled runs the Go function behind a Runtime.bindingCalled event and
+// settles the promise returned to the page, or ends the iteration of a stream.
+func (_this *Page) bindingCalled(params json.RawMessage) {
+
+	event := struct {
+		Name    string `json:"name"`
+		Payload string `json:"payload"`
+		ID      int    `json:"executionContextId"`
+	}{}
+	json.Unmarshal(params, &event)
+
+	payload := struct {
+		Name  string            `json:"name"`
+		Seq   int               `json:"seq"`
+		Args  []json.RawMessage `json:"args"`
+		Abort bool              `json:"abort"`
+	}{}
+	json.Unmarshal([]byte(event.Payload), &payload)
+
+	key := callKey{context: event.ID, name: payload.Name, seq: payload.Seq}
+
+	if payload.Abort {
+		_this.cancelCalls(func(k callKey) bool { return k == key })
+		return
+	}
+
+	_this.Lock()
+	binding, ok := _this.bindings[event.Name]
+	ctx, cancel := context.WithCancel(context.Background())
+	if ok {
+		_this.calls[key] = cancel
+	}
+	_this.Unlock()
+
+	if !ok {
+		cancel()
+		return
+	}
+
+	ctx = context.WithValue(ctx, blobKey{}, blobFunc(func(index int, offset int64, size int) ([]byte, error) {
+		res, err := _this.sendContext(ctx, "Runtime.callFunctionOn", h{
+			"functionDeclaration": bindingBlob,
+			"executionContextId":  event.ID,
+			"arguments":           []h{{"value": payload.Name}, {"value": payload.Seq}, {"value": index}, {"value": offset}, {"value": size}},
+			"awaitPromise":        true,
+			"returnByValue":       true,
+		})
+		if err != nil {
+			return nil, err
+		}
+		// Sent as a base64 string
+		chunk := []byte{}
+		err = json.Unmarshal(res, &chunk)
+		return chunk, err
+	}))
+
+	ctx = context.WithValue(ctx, streamKey{}, streamFunc(func(item interface{}) error {
+		b, err := marshalResult(item)
+		if err != nil {
+			return err
+		}
+		// Waiting for each item to be queued keeps them in order and paces the Go side
+		_, err = _this.sendContext(ctx, "Runtime.callFunctionOn", h{
+			"functionDeclaration": bindingStream,
+			"executionContextId":  event.ID,
+			"arguments":           []h{{"value": payload.Name}, {"value": payload.Seq}, {"value": json.RawMessage(b)}},
+		})
+		return err
+	}))
+
+	go func() {
+		defer func() {
+			_this.Lock()
+			delete(_this.calls, key)
+			_this.Unlock()
+			cancel()
+		}()
+
+		_this.Lock()
+		source := _this.contexts[event.ID]
+		_this.Unlock()
+
+		call := &BindingCall{
+			Page:      _this,
+			Name:      event.Name,
+			Args:      payload.Args,
+			ContextID: event.ID,
+			FrameID:   source.FrameID,
+			Origin:    source.Origin,
+		}
+
+		handler := _this.browser.bindingHandler(func(ctx context.Context, call *BindingCall) (interface{}, error) {
+			return binding(ctx, call.Args)
+		})
+
+		var error interface{}
+		result := json.RawMessage("null")
+		if err := _this.checkOrigin(call); err != nil {
+			// Neither the middleware nor the function see calls from origins not allowed
+			error = newBindingError(err)
+		} else if b, err := _this.callBinding(handler, ctx, call); err != nil {
+			error = newBindingError(err)
+		} else {
+			result = b
+		}
+		_this.send("Runtime.callFunctionOn", h{
+			"functionDeclaration": bindingReply,
+			"executionContextId":  event.ID,
+			"arguments":           []h{{"value": payload.Name}, {"value": payload.Seq}, {"value": result}, {"value": error}},
+		})
+	}()
+
+}
+
+// callBinding runs a binding and its middleware and encodes the result, turning a panic,
+// also one of a MarshalJSON or Read method of the result, into a *BindingPanic error so it
+// rejects the JS promise instead of crashing the application.
+func (_this *Page) callBinding(handler BindingHandler, ctx context.Context, call *BindingCall) (b json.RawMessage, err error) {
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = &BindingPanic{Value: p, Stack: debug.Stack()}
+			if _this.browser.config.Debug {
+				log.Printf("%v\n%s", p, err.(*BindingPanic).Stack)
+			}
+		}
+	}()
+
+	r, err := handler(ctx, call)
+	if err != nil {
+		return nil, err
+	}
+
+	return marshalResult(r)
+}
+
+func (_this *Page) bind(name string, f bindingFunc, stream bool) error {
+	_this.Lock()
+	// check if binding already exists
+	_, exists := _this.bindings[name]
+
+	_this.bindings[name] = f
+	_this.Unlock()
+
+	if exists {
+		// Just replace callback and return, as the binding was already added to js
+		// and adding it again would break it.
+		return nil
+	}
+
+	if _, err := _this.send("Runtime.addBinding", h{"name": name}); err != nil {
+		return err
+	}
+	script := fmt.Sprintf(`(() => {
+	const bindingName = '%s';
+	const stream = %t;
+	const binding = window[bindingName];
+	const call = (args) => {
+		const me = window[bindingName];
+		for (const key of ['callbacks', 'errors', 'streams', 'blobs']) {
+			if (!me[key]) {
+				me[key] = new Map();
+			}
+		}
+		const callbacks = me['callbacks'];
+		const errors = me['errors'];
+		const streams = me['streams'];
+		const blobs = me['blobs'];
+		const seq = (me['lastSeq'] || 0) + 1;
+		me['lastSeq'] = seq;
+		// A trailing AbortSignal cancels the context of the Go function
+		let signal;
+		if (typeof AbortSignal !== 'undefined' && args[args.length - 1] instanceof AbortSignal) {
+			signal = args.pop();
+		}
+		const cancel = (reason) => {
+			const reject = errors.get(seq);
+			if (!reject) {
+				return;
+			}
+			callbacks.delete(seq);
+			errors.delete(seq);
+			streams.delete(seq);
+			blobs.delete(seq);
+			binding(JSON.stringify({name: bindingName, seq, abort: true}));
+			reject(reason);
+		};
+		let result;
+		if (stream) {
+			// Items streamed from Go wait in the queue until the iterator asks for them
+			const queue = [];
+			let waiting = [];
+			let finished = false;
+			let failure;
+			const wake = () => waiting.splice(0).forEach((resolve) => resolve());
+			streams.set(seq, (item) => {
+				queue.push(item);
+				wake();
+			});
+			callbacks.set(seq, () => {
+				finished = true;
+				wake();
+			});
+			errors.set(seq, (e) => {
+				failure = e;
+				finished = true;
+				wake();
+			});
+			result = {
+				[Symbol.asyncIterator]() {
+					return this;
+				},
+				async next() {
+					while (!queue.length && !finished) {
+						await new Promise((resolve) => waiting.push(resolve));
+					}
+					if (queue.length) {
+						return {value: queue.shift(), done: false};
+					}
+					if (failure !== undefined) {
+						const e = failure;
+						failure = undefined;
+						throw e;
+					}
+					return {value: undefined, done: true};
+				},
+				// Leaving a for await loop early cancels the Go function
+				async return() {
+					cancel(undefined);
+					return {value: undefined, done: true};
+				},
+			};
+		} else {
+			result = new Promise((resolve, reject) => {
+				callbacks.set(seq, resolve);
+				errors.set(seq, reject);
+			});
+		}
+		// Binary data is encoded for Go, Blobs stay here until Go reads them
+		const callBlobs = [];
+		const payload = JSON.stringify({name: bindingName, seq, args}, window.proton.binary.replacer(callBlobs));
+		if (callBlobs.length) {
+			blobs.set(seq, callBlobs);
+		}
+		binding(payload);
+		if (signal) {
+			const abort = () => cancel(signal.reason !== undefined ? signal.reason : new DOMException('The operation was aborted.', 'AbortError'));
+			if (signal.aborted) {
+				abort();
+			} else {
+				signal.addEventListener('abort', abort, {once: true});
+			}
+		}
+		return result;
+	};
+	// A stream returns its async iterator synchronously, anything else a promise
+	window[bindingName] = stream ? (...args) => call(args) : async (...args) => call(args);
+	// Dotted names, as bound by BindObject, are also exposed as nested objects
+	const path = bindingName.split('.');
+	if (path.length > 1) {
+		let namespace = window;
+		for (const key of path.slice(0, -1)) {
+			namespace = namespace[key] = namespace[key] || {};
+		}
+		namespace[path[path.length - 1]] = window[bindingName];
+	}
+	})();
+	`, name, stream)
+
+	id, err := _this.addScript(script)
+
+	_this.Lock()
+	_this.scripts[name] = id
+	_this.Unlock()
+
+	return err
+}
+
+// addScript runs script in the current document and in every document loaded afterwards.
+// It returns the identifier of the script, to remove it with Page.removeScriptToEvaluateOnNewDocument.
+func (_this *Page) addScript(script string) (string, error) {
+
+	res, err := _this.send("Page.addScriptToEvaluateOnNewDocument", h{"source": script})
+	if err != nil {
+		return "", err
+	}
+
+	added := struct {
+		Identifier string `json:"identifier"`
+	}{}
+	json.Unmarshal(res, &added)
+
+	awaitPromise := true
+	returnByValue := true
+
+	_, err = _this.RuntimeEvaluate(context.Background(), RuntimeEvaluateParameters{Expression: script, AwaitPromise: &awaitPromise, ReturnByValue: &returnByValue})
+
+	return added.Identifier, err
+}
+
+func (_this *Page) setBounds(b Bounds) error {
+	if b.WindowState == "" {
+		b.WindowState = WindowStateNormal
+	}
+	param := h{"windowId": _this.window, "bounds": b}
+	if b.WindowState != WindowStateNormal {
+		param["bounds"] = h{"windowState": b.WindowState}
+	}
+	_, err := _this.browser.send("Browser.setWindowBounds", param)
+	return err
+}
+
+func (_this *Page) bounds() (Bounds, error) {
+	result, err := _this.browser.send("Browser.getWindowBounds", h{"windowId": _this.window})
+	if err != nil {
+		return Bounds{}, err
+	}
+	bounds := struct {
+		Bounds Bounds `json:"bounds"`
+	}{}
+	err = json.Unmarshal(result, &bounds)
+	return bounds.Bounds, err
+}
+
+// Bind exposes the Go function f to the page as window[name].
+//
+// When the first parameter of f is a context.Context, it receives a context that is
+// cancelled when the page navigates away, the window is closed, or the JS caller
+// aborts the AbortSignal passed as the last argument of the call.
+//
+// Arguments are decoded with encoding/json. Trailing arguments the caller leaves out
+// are passed as zero values, nil for pointers, and a variadic f receives the arguments
+// left over by its fixed parameters. Arguments that can't be decoded reject the call
+// with an *ArgumentError.
+//
+// Binary data needs no encoding on either side: a Uint8Array, any other typed array,
+// an ArrayBuffer, a Blob or a File passed from JS decodes into a []byte or an io.Reader
+// parameter, and a []byte or io.Reader returned by f resolves as a Uint8Array. Named byte
+// slices count as []byte, unless they encode themselves like json.RawMessage. An io.Reader
+// reads a Blob as it is consumed and must not be used after f returns.
+//
+// When f returns a <-chan T, or takes a func(T) parameter that is not passed from JS,
+// the JS function returns an async iterator yielding each item sent on the channel or
+// passed to the callback. The iteration ends when the channel is closed or f returns,
+// an error returned by f rejects it, and leaving the loop early cancels the context.
+func (_this *Page) Bind(name string, f interface{}) error {
+
+	binding, err := newBindingFunc(reflect.ValueOf(f))
+	if err != nil {
+		return err
+	}
+
+	_this.Lock()
+	_this.signatures[name] = reflect.TypeOf(f)
+	_this.Unlock()
+
+	return _this.bind(name, binding, streamType(reflect.TypeOf(f)) != nil)
+}
+
+// BindObject exposes every exported method of v to the page as window[name][method].
+// The method set is read at bind time, so methods added to the type later need no extra wiring.
+// Methods with a pointer receiver are only exposed when v is a pointer.
+func (_this *Page) BindObject(name string, v interface{}) error {
+
+	obj := reflect.ValueOf(v)
+
+	if !obj.IsValid() {
+		return errors.New("object is nil")
+	}
+
+	if obj.NumMethod() == 0 {
+		return errors.New("object has no exported methods")
+	}
+
+	// Every method is checked before any is bound, so a bad one binds nothing
+	bindings := make([]bindingFunc, obj.NumMethod())
+
+	for i := range bindings {
+
+		binding, err := newBindingFunc(obj.Method(i))
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", name, obj.Type().Method(i).Name, err)
+		}
+
+		bindings[i] = binding
+	}
+
+	for i, binding := range bindings {
+
+		method := obj.Type().Method(i).Name
+
+		_this.Lock()
+		_this.signatures[name+"."+method] = obj.Method(i).Type()
+		_this.Unlock()
+
+		if err := _this.bind(name+"."+method, binding, streamType(obj.Method(i).Type()) != nil); err != nil {
+			return err
+		}
+
+	}
+
+	return nil
+}
+
+// Unbind removes the function bound as name, or every method bound under name by BindObject.
+// Calls in progress are cancelled and their promises rejected.
+func (_this *Page) Unbind(name string) error {
+
+	_this.Lock()
+	names := []string{}
+	for bound := range _this.bindings {
+		if bound == name || strings.HasPrefix(bound, name+".") {
+			names = append(names, bound)
+		}
+	}
+	_this.Unlock()
+
+	if len(names) == 0 {
+		return fmt.Errorf("%s is not bound", name)
+	}
+
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err := _this.unbind(name); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (_this *Page) unbind(name string) error {
+
+	_this.Lock()
+	script := _this.scripts[name]
+	delete(_this.bindings, name)
+	delete(_this.signatures, name)
+	delete(_this.scripts, name)
+	_this.Unlock()
+
+	_this.cancelCalls(func(k callKey) bool { return k.name == name })
+
+	if _, err := _this.send("Runtime.removeBinding", h{"name": name}); err != nil {
+		return err
+	}
+
+	if script != "" {
+		if _, err := _this.send("Page.removeScriptToEvaluateOnNewDocument", h{"identifier": script}); err != nil {
+			return err
+		}
+	}
+
+	// The name is passed as JSON, a valid JS literal
+	arg, _ := json.Marshal(name)
+	_, err := _this.RuntimeEvaluate(context.Background(), RuntimeEvaluateParameters{Expression: "(" + bindingRemove + ")(" + string(arg) + ")"})
+
+	return err
+}
+
+// Bindings returns the names of the functions bound to the page, sorted.
+// Methods bound by BindObject are listed with their dotted name.
+func (_this *Page) Bindings() []string {
+	_this.Lock()
+	defer _this.Unlock()
+
+	names := make([]string, 0, len(_this.bindings))
+	for name := range _this.bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// streamKey is the context key of the streamFunc of a binding call.
+type streamKey struct{}
+
+// streamFunc sends an item of a streamed result to the page.
+type streamFunc func(item interface{}) error
+
+// isStreamCallback reports whether a parameter of type t is a func(T) receiving the items of a stream.
+func isStreamCallback(t reflect.Type) bool {
+	return t.Kind() == reflect.Func && t.NumIn() == 1 && t.NumOut() == 0
+}
+
+// streamType returns the type of the items streamed by a bound function of type t,
+// or nil when its result is a single value.
+func streamType(t reflect.Type) reflect.Type {
+
+	for i := 0; i < t.NumIn(); i++ {
+		if isStreamCallback(t.In(i)) {
+			return t.In(i).In(0)
+		}
+	}
+
+	if t.NumOut() > 0 && t.Out(0).Kind() == reflect.Chan && t.Out(0).ChanDir()&reflect.RecvDir != 0 {
+		return t.Out(0).Elem()
+	}
+
+	return nil
+}
+
+// newBindingFunc wraps a Go function so it can be called with the JSON arguments of a binding call.
+// A func(T) parameter is not passed from JS, it streams the items it is called with.
+func newBindingFunc(v reflect.Value) (bindingFunc, error) {
+	// f must be a function
+	if v.Kind() != reflect.Func {
+		return nil, errors.New("only functions can be bound")
+	}
+	// f must return either value and error or just error
+	if n := v.Type().NumOut(); n > 2 {
+		return nil, errors.New("function may only return a value or a value+error")
+	}
+
+	// f may take a context.Context before the JS arguments
+	offset := 0
+	if v.Type().NumIn() > 0 && v.Type().In(0) == contextType {
+		offset = 1
+	}
+
+	// The arguments left over by the fixed parameters go to a variadic one
+	variadic := v.Type().IsVariadic()
+	last := v.Type().NumIn() - 1
+
+	// Fixed parameters filled from JS, the others are stream callbacks
+	params := []int{}
+	for i := offset; i < v.Type().NumIn(); i++ {
+		if !isStreamCallback(v.Type().In(i)) && !(variadic && i == last) {
+			params = append(params, i)
+		}
+	}
+
+	return func(ctx context.Context, raw []json.RawMessage) (interface{}, error) {
+		if len(raw) > len(params) && !variadic {
+			return nil, &ArgumentError{Index: len(params), Err: fmt.Errorf("function takes %d arguments, got %d", len(params), len(raw))}
+		}
+
+		stream, _ := ctx.Value(streamKey{}).(streamFunc)
+		if stream == nil {
+			stream = func(interface{}) error { return nil }
+		}
+		// The first failure to stream an item from a callback rejects the call once f returns
+		var streamErr error
+		var streamMu sync.Mutex
+
+		args := make([]reflect.Value, v.Type().NumIn())
+		if offset == 1 {
+			args[0] = reflect.ValueOf(&ctx).Elem()
+		}
+		for i := offset; i < v.Type().NumIn(); i++ {
+			if t := v.Type().In(i); isStreamCallback(t) {
+				args[i] = reflect.MakeFunc(t, func(in []reflect.Value) []reflect.Value {
+					if err := stream(in[0].Interface()); err != nil {
+						streamMu.Lock()
+						if streamErr == nil {
+							streamErr = err
+						}
+						streamMu.Unlock()
+					}
+					return nil
+				})
+			}
+		}
+		for i, p := range params {
+			if i >= len(raw) {
+				// Trailing arguments left out by the caller
+				args[p] = reflect.Zero(v.Type().In(p))
+				continue
+			}
+			arg, err := bindingArgument(ctx, raw[i], v.Type().In(p), i)
+			if err != nil {
+				return nil, err
+			}
+			args[p] = arg
+		}
+		if variadic {
+			args = args[:last]
+			for i := len(params); i < len(raw); i++ {
+				arg, err := bindingArgument(ctx, raw[i], v.Type().In(last).Elem(), i)
+				if err != nil {
+					return nil, err
+				}
+				args = append(args, arg)
+			}
+		}
+
+		r, err := bindingResults(v.Call(args))
+		if err != nil {
+			return nil, err
+		}
+		streamMu.Lock()
+		err = streamErr
+		streamMu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+
+		if c := reflect.ValueOf(r); c.Kind() == reflect.Chan && c.Type().ChanDir()&reflect.RecvDir != 0 {
+			return nil, drain(ctx, c, stream)
+		}
+
+		return r, nil
+	}, nil
+}
+
+// bindingArgument decodes the JS argument at index into a value of type t.
+// Typed arrays and Blobs decode into []byte, or into an io.Reader.
+func bindingArgument(ctx context.Context, raw json.RawMessage, t reflect.Type, index int) (reflect.Value, error) {
+
+	if t == readerType {
+		r, err := binaryReader(ctx, raw)
+		if err != nil {
+			return reflect.Value{}, &ArgumentError{Index: index, Type: t, Err: err}
+		}
+		return reflect.ValueOf(&r).Elem(), nil
+	}
+
+	raw, err := inlineBinary(ctx, raw)
+	if err != nil {
+		return reflect.Value{}, &ArgumentError{Index: index, Type: t, Err: err}
+	}
+
+	arg := reflect.New(t)
+	if err := json.Unmarshal(raw, arg.Interface()); err != nil {
+		return reflect.Value{}, &ArgumentError{Index: index, Type: t, Err: err}
+	}
+
+	return arg.Elem(), nil
+}
+
+// bindingResults returns the value and the error returned by a bound function.
+func bindingResults(res []reflect.Value) (interface{}, error) {
+
+	switch len(res) {
+	case 0:
+		// No results from the function, just return nil
+		return nil, nil
+	case 1:
+		// One result may be a value, or an error
+		if res[0].Type().Implements(errorType) {
+			if res[0].Interface() != nil {
+				return nil, res[0].Interface().(error)
+			}
+			return nil, nil
+		}
+		return res[0].Interface(), nil
+	case 2:
+		// Two results: first one is value, second is error
+		if !res[1].Type().Implements(errorType) {
+			return nil, errors.New("second return value must be an error")
+		}
+		if res[1].Interface() == nil {
+			return res[0].Interface(), nil
+		}
+		return res[0].Interface(), res[1].Interface().(error)
+	default:
+		return nil, errors.New("unexpected number of return values")
+	}
+}
+
+// drain streams the items received from c until it is closed or ctx is done.
+func drain(ctx context.Context, c reflect.Value, stream streamFunc) error {
+
+	if c.IsNil() {
+		return nil
+	}
+
+	cases := []reflect.SelectCase{
+		{Dir: reflect.SelectRecv, Chan: c},
+		{Dir: reflect.SelectRecv, Chan: reflect.ValueOf(ctx.Done())},
+	}
+
+	for {
+		chosen, item, ok := reflect.Select(cases)
+		if chosen == 1 {
+			return ctx.Err()
+		}
+		if !ok {
+			return nil
+		}
+		if err := stream(item.Interface()); err != nil {
+			return err
+		}
+	}
+}
+
+// Eval evaluates the JS expression, awaiting the result if it is a promise.
+func (_this *Page) Eval(js string) Value {
+	return _this.EvalContext(context.Background(), js)
+}
+
+// EvalContext is like Eval, but stops waiting when the context is done.
+func (_this *Page) EvalContext(ctx context.Context, js string) Value {
+
+	awaitPromise := true
+	returnByValue := true
+
+	v, err := _this.RuntimeEvaluate(ctx, RuntimeEvaluateParameters{Expression: js, AwaitPromise: &awaitPromise, ReturnByValue: &returnByValue})
+	return value{err: err, raw: v}
+}
+
+// Call calls the JS function fn with args and returns its result, awaiting it if it is a promise.
+// fn is a function declaration such as "(a, b) => a + b", args are encoded with
+// encoding/json and passed as protocol call arguments, so they are never spliced into
+// source text. A RuntimeCallArgument is passed as is and a *RemoteObject by reference. Call returns ErrNoContext while
+// the page is between documents.
+func (_this *Page) Call(fn string, args ...interface{}) Value {
+	return _this.CallContext(context.Background(), fn, args...)
+}
+
+// CallContext is like Call, but stops waiting when the context is done.
+func (_this *Page) CallContext(ctx context.Context, fn string, args ...interface{}) Value {
+
+	_this.Lock()
+	id := _this.context
+	_this.Unlock()
+
+	if id == 0 {
+		return value{err: ErrNoContext}
+	}
+
+	v, err := _this.callFunction(ctx, fn, h{"executionContextId": id, "returnByValue": true}, args)
+	return value{err: err, raw: v}
+}
+
+// callFunction sends Runtime.callFunctionOn with the encoded args, awaiting promises.
+// params chooses where the function runs and how the result is returned.
+func (_this *Page) callFunction(ctx context.Context, fn string, params h, args []interface{}) (json.RawMessage, error) {
+
+	arguments := make([]RuntimeCallArgument, len(args))
+
+	for i, arg := range args {
+		a, err := callArgument(arg)
+		if err != nil {
+			return nil, fmt.Errorf("argument %d: %w", i, err)
+		}
+		arguments[i] = a
+	}
+
+	params["functionDeclaration"] = fn
+	params["arguments"] = arguments
+	params["awaitPromise"] = true
+
+	return _this.sendContext(ctx, "Runtime.callFunctionOn", params)
+}
+
+// callArgument encodes a Go value as a call argument, using the unserializable
+// form for the floats JSON has no representation for.
+func callArgument(v interface{}) (RuntimeCallArgument, error) {
+
+	switch a := v.(type) {
+	case RuntimeCallArgument:
+		return a, nil
+	case *RemoteObject:
+		return a.argument(), nil
+	case float64:
+		if s, ok := unserializable(a); ok {
+			return RuntimeCallArgument{UnserializableValue: &s}, nil
+		}
+	case float32:
+		if s, ok := unserializable(float64(a)); ok {
+			return RuntimeCallArgument{UnserializableValue: &s}, nil
+		}
+	}
+
+	b, err := json.Marshal(v)
+	if err != nil {
+		return RuntimeCallArgument{}, err
+	}
+
+	return RuntimeCallArgument{Value: b}, nil
+}
+
+func unserializable(f float64) (string, bool) {
+
+	switch {
+	case math.IsNaN(f):
+		return "NaN", true
+	case math.IsInf(f, 1):
+		return "Infinity", true
+	case math.IsInf(f, -1):
+		return "-Infinity", true
+	}
+
+	return "", false
+}
+
+// SendContext sends a raw DevTools Protocol command to the page session and returns its result.
+// It stops waiting and returns ctx.Err() when the context is done.
+func (_this *Page) SendContext(ctx context.Context, method string, params map[string]interface{}) (json.RawMessage, error) {
+
+	if params == nil {
+		params = h{}
+	}
+
+	return _this.sendContext(ctx, method, params)
+}
+
+// On registers a handler for a DevTools Protocol event of this page. See Browser.On.
+func (_this *Page) On(method string, handler func(params json.RawMessage)) int {
+	return _this.browser.on(_this.session, method, handler)
+}
+
+// Off removes the handler registered by On for the given event.
+func (_this *Page) Off(method string, id int) {
+	_this.browser.Off(method, id)
+}
+
+// Subscribe is like Browser.Subscribe, limited to the events of this page.
+// The channel is also closed when the page is closed.
+func (_this *Page) Subscribe(method string) (<-chan json.RawMessage, func()) {
+	return _this.browser.subscribe(_this.session, method, _this.done)
+}
+
+func (_this *Page) SetBounds(b Bounds) error {
+	return _this.setBounds(b)
+}
+
+func (_this *Page) Bounds() (Bounds, error) {
+	return _this.bounds()
+}
+
+// TargetID returns the DevTools target identifier of the page.
+func (_this *Page) TargetID() string {
+	return _this.target
+}
+
+// Close closes the page and its window.
+func (_this *Page) Close() error {
+
+	_, err := _this.browser.send("Target.closeTarget", h{"targetId": _this.target})
+
+	return err
+}
+
+// Done is closed when the page is closed or the browser goes away.
+func (_this *Page) Done() <-chan struct{} {
+	return _this.done
+}
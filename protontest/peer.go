@@ -0,0 +1,514 @@
+// Package protontest provides a scripted, in-memory DevTools Protocol peer that a
+// proton.Browser can run on, so bindings and UI logic can be tested without a browser.
+//
+//	peer := protontest.NewPeer()
+//	browser := proton.Browser{}
+//	browser.ConnectTransport(peer)
+//	browser.Page().Bind("add", func(a, b int) int { return a + b })
+//	res, err := peer.CallBinding("add", 1, 2)
+package protontest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"github.com/leandroveronezi/proton"
+)
+
+// Handler answers a command sent by the browser. The returned value is encoded as
+// the command result, a returned error as a protocol error. Return a
+// *proton.ProtocolError to control the error code and data.
+type Handler func(params json.RawMessage) (interface{}, error)
+
+// Command is a command received from the browser.
+type Command struct {
+	ID        int             `json:"id"`
+	Method    string          `json:"method"`
+	Params    json.RawMessage `json:"params"`
+	SessionID string          `json:"sessionId"`
+}
+
+// BindingError is returned by CallBinding when the bound Go function failed.
+type BindingError struct {
+	Value json.RawMessage // Value the binding promise was rejected with
+}
+
+func (_this *BindingError) Error() string {
+
+	var s string
+	if json.Unmarshal(_this.Value, &s) == nil {
+		return s
+	}
+
+	e := struct {
+		Message string `json:"message"`
+	}{}
+	if json.Unmarshal(_this.Value, &e) == nil && e.Message != "" {
+		return e.Message
+	}
+
+	return string(_this.Value)
+}
+
+// Uint8Array is passed to CallBinding as a JS typed array would be.
+type Uint8Array []byte
+
+func (_this Uint8Array) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string][]byte{"$binary": _this})
+}
+
+// Blob is passed to CallBinding as a JS Blob or File would be, the browser reads
+// its content from the peer as the bound function consumes it.
+type Blob struct {
+	Name string
+	Type string
+	Data []byte
+}
+
+type bindingResult struct {
+	result json.RawMessage
+	items  []json.RawMessage
+	err    error
+}
+
+// pendingBinding is a binding call waiting to be settled by the browser.
+type pendingBinding struct {
+	done  chan bindingResult
+	items []json.RawMessage
+	blobs []Blob
+}
+
+// Peer is a fake browser implementing proton.Transport. It exposes a single page
+// target on start, answers every command with an empty result unless a handler
+// was registered with Handle, and records every command it receives. Each page
+// gets a document with execution context 1 when it enables the Runtime domain.
+type Peer struct {
+	sync.Mutex
+	cond     *sync.Cond
+	outbox   [][]byte
+	closed   bool
+	handlers map[string]Handler
+	commands []Command
+	targets  int
+	scripts  int
+	sessions []string
+	bindings map[string]*pendingBinding
+	seq      int
+}
+
+var _ proton.Transport = (*Peer)(nil)
+
+// NewPeer returns a peer ready to be passed to Browser.ConnectTransport.
+func NewPeer() *Peer {
+
+	p := &Peer{
+		handlers: map[string]Handler{},
+		bindings: map[string]*pendingBinding{},
+	}
+	p.cond = sync.NewCond(&p.Mutex)
+
+	p.handlers["Target.setDiscoverTargets"] = func(json.RawMessage) (interface{}, error) {
+		p.Emit("", "Target.targetCreated", map[string]interface{}{
+			"targetInfo": map[string]interface{}{"targetId": p.newTarget(), "type": "page"},
+		})
+		return nil, nil
+	}
+	p.handlers["Target.createTarget"] = func(json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"targetId": p.newTarget()}, nil
+	}
+	p.handlers["Target.attachToTarget"] = func(params json.RawMessage) (interface{}, error) {
+		target := struct {
+			ID string `json:"targetId"`
+		}{}
+		json.Unmarshal(params, &target)
+		session := "session-" + target.ID
+		p.Lock()
+		p.sessions = append(p.sessions, session)
+		p.Unlock()
+		return map[string]interface{}{"sessionId": session}, nil
+	}
+	p.handlers["Page.addScriptToEvaluateOnNewDocument"] = func(json.RawMessage) (interface{}, error) {
+		p.Lock()
+		defer p.Unlock()
+		p.scripts++
+		return map[string]interface{}{"identifier": fmt.Sprintf("script-%d", p.scripts)}, nil
+	}
+	p.handlers["Browser.getWindowForTarget"] = func(json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"windowId": 1, "bounds": map[string]interface{}{}}, nil
+	}
+	p.handlers["Runtime.evaluate"] = func(json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"result": map[string]interface{}{"type": "undefined"}}, nil
+	}
+	p.handlers["Runtime.callFunctionOn"] = p.handlers["Runtime.evaluate"]
+
+	return p
+}
+
+func (_this *Peer) newTarget() string {
+	_this.Lock()
+	defer _this.Unlock()
+
+	_this.targets++
+	return fmt.Sprintf("target-%d", _this.targets)
+}
+
+// Handle registers the handler answering the given command method, replacing the default one.
+func (_this *Peer) Handle(method string, handler Handler) {
+	_this.Lock()
+	defer _this.Unlock()
+
+	_this.handlers[method] = handler
+}
+
+// Emit sends an event to the browser. An empty session emits a browser-level event.
+func (_this *Peer) Emit(session string, method string, params interface{}) {
+
+	m := map[string]interface{}{"method": method, "params": params}
+	if session != "" {
+		m["sessionId"] = session
+	}
+
+	b, _ := json.Marshal(m)
+	_this.push(b)
+}
+
+// Sessions returns the sessions attached so far, the page opened on start first.
+func (_this *Peer) Sessions() []string {
+	_this.Lock()
+	defer _this.Unlock()
+
+	return append([]string(nil), _this.sessions...)
+}
+
+// Commands returns the commands received so far with the given method,
+// or every command when method is empty.
+func (_this *Peer) Commands(method string) []Command {
+	_this.Lock()
+	defer _this.Unlock()
+
+	list := []Command{}
+	for _, c := range _this.commands {
+		if method == "" || c.Method == method {
+			list = append(list, c)
+		}
+	}
+
+	return list
+}
+
+// CallBinding simulates the page calling the bound function name with args on the
+// first page session, and waits for the browser to settle the call. Pass a Uint8Array
+// or a Blob for binary arguments.
+func (_this *Peer) CallBinding(name string, args ...interface{}) (json.RawMessage, error) {
+	return _this.CallBindingContext(context.Background(), name, args...)
+}
+
+// CallBindingContext is like CallBinding, aborting the call as a JS AbortSignal
+// would when ctx is done.
+func (_this *Peer) CallBindingContext(ctx context.Context, name string, args ...interface{}) (json.RawMessage, error) {
+
+	res, err := _this.callBinding(ctx, name, args)
+
+	return res.result, err
+}
+
+// StreamBinding is like CallBinding for a function streaming its result, it returns
+// the items streamed before the iteration ended.
+func (_this *Peer) StreamBinding(name string, args ...interface{}) ([]json.RawMessage, error) {
+
+	res, err := _this.callBinding(context.Background(), name, args)
+
+	return res.items, err
+}
+
+func (_this *Peer) callBinding(ctx context.Context, name string, args []interface{}) (bindingResult, error) {
+
+	sessions := _this.Sessions()
+	if len(sessions) == 0 {
+		return bindingResult{}, errors.New("no session attached")
+	}
+
+	call := &pendingBinding{done: make(chan bindingResult, 1)}
+
+	// Blobs stay in the page, the call only carries a reference to them
+	values := []interface{}{}
+	for _, arg := range args {
+		if b, ok := arg.(Blob); ok {
+			arg = map[string]interface{}{"$blob": len(call.blobs), "size": len(b.Data), "type": b.Type, "name": b.Name}
+			call.blobs = append(call.blobs, b)
+		}
+		values = append(values, arg)
+	}
+	args = values
+
+	_this.Lock()
+	_this.seq++
+	seq := _this.seq
+	key := fmt.Sprintf("%s#%d", name, seq)
+	_this.bindings[key] = call
+	_this.Unlock()
+
+	payload, err := json.Marshal(map[string]interface{}{"name": name, "seq": seq, "args": args})
+	if err != nil {
+		return bindingResult{}, err
+	}
+
+	_this.Emit(sessions[0], "Runtime.bindingCalled", map[string]interface{}{
+		"name":               name,
+		"payload":            string(payload),
+		"executionContextId": 1,
+	})
+
+	select {
+	case res := <-call.done:
+		return res, res.err
+	case <-ctx.Done():
+	}
+
+	_this.Lock()
+	delete(_this.bindings, key)
+	_this.Unlock()
+
+	payload, _ = json.Marshal(map[string]interface{}{"name": name, "seq": seq, "abort": true})
+
+	_this.Emit(sessions[0], "Runtime.bindingCalled", map[string]interface{}{
+		"name":               name,
+		"payload":            string(payload),
+		"executionContextId": 1,
+	})
+
+	return bindingResult{}, ctx.Err()
+}
+
+// settle completes a CallBinding when the browser replies to a binding call,
+// or records an item when it streams one.
+func (_this *Peer) settle(params json.RawMessage) {
+
+	call := struct {
+		Arguments []struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"arguments"`
+	}{}
+
+	// Replies take 4 arguments and streamed items 3
+	if json.Unmarshal(params, &call) != nil || len(call.Arguments) < 3 || len(call.Arguments) > 4 {
+		return
+	}
+
+	var name string
+	var seq int
+	json.Unmarshal(call.Arguments[0].Value, &name)
+	json.Unmarshal(call.Arguments[1].Value, &seq)
+
+	key := fmt.Sprintf("%s#%d", name, seq)
+
+	_this.Lock()
+	pending, ok := _this.bindings[key]
+	if ok && len(call.Arguments) == 3 {
+		pending.items = append(pending.items, call.Arguments[2].Value)
+		_this.Unlock()
+		return
+	}
+	delete(_this.bindings, key)
+	_this.Unlock()
+
+	if !ok {
+		return
+	}
+
+	res := bindingResult{result: call.Arguments[2].Value, items: pending.items}
+
+	var failed interface{}
+	json.Unmarshal(call.Arguments[3].Value, &failed)
+	if failed != nil && failed != "" && failed != false {
+		res = bindingResult{items: pending.items, err: &BindingError{Value: call.Arguments[3].Value}}
+	}
+
+	pending.done <- res
+}
+
+// SetOrigin navigates the first page session to a document of the given origin,
+// binding calls then come from this origin.
+func (_this *Peer) SetOrigin(origin string) {
+
+	if sessions := _this.Sessions(); len(sessions) > 0 {
+		_this.Emit(sessions[0], "Runtime.executionContextsCleared", map[string]interface{}{})
+		_this.createContext(sessions[0], origin)
+	}
+}
+
+// createContext reports the document of a page, the main frame shares its identifier with the target.
+func (_this *Peer) createContext(session string, origin string) {
+
+	_this.Emit(session, "Runtime.executionContextCreated", map[string]interface{}{
+		"context": map[string]interface{}{
+			"id":      1,
+			"origin":  origin,
+			"name":    "",
+			"auxData": map[string]interface{}{"isDefault": true, "frameId": strings.TrimPrefix(session, "session-")},
+		},
+	})
+}
+
+// readBlob answers the browser reading a slice of a Blob passed to CallBinding.
+func (_this *Peer) readBlob(params json.RawMessage) (Handler, bool) {
+
+	call := struct {
+		Arguments []struct {
+			Value json.RawMessage `json:"value"`
+		} `json:"arguments"`
+	}{}
+
+	if json.Unmarshal(params, &call) != nil || len(call.Arguments) != 5 {
+		return nil, false
+	}
+
+	var name string
+	var seq, index, offset, size int
+	json.Unmarshal(call.Arguments[0].Value, &name)
+	json.Unmarshal(call.Arguments[1].Value, &seq)
+	json.Unmarshal(call.Arguments[2].Value, &index)
+	json.Unmarshal(call.Arguments[3].Value, &offset)
+	json.Unmarshal(call.Arguments[4].Value, &size)
+
+	_this.Lock()
+	pending, ok := _this.bindings[fmt.Sprintf("%s#%d", name, seq)]
+	_this.Unlock()
+
+	if !ok || index >= len(pending.blobs) {
+		return nil, false
+	}
+
+	data := pending.blobs[index].Data
+	if offset > len(data) {
+		offset = len(data)
+	}
+	if offset+size > len(data) {
+		size = len(data) - offset
+	}
+	chunk := data[offset : offset+size]
+
+	return func(json.RawMessage) (interface{}, error) {
+		return map[string]interface{}{"result": map[string]interface{}{"type": "string", "value": chunk}}, nil
+	}, true
+}
+
+func (_this *Peer) push(b []byte) {
+	_this.Lock()
+	defer _this.Unlock()
+
+	if _this.closed {
+		return
+	}
+
+	_this.outbox = append(_this.outbox, b)
+	_this.cond.Signal()
+}
+
+// Send receives a command from the browser and answers it in the background.
+func (_this *Peer) Send(data []byte) error {
+
+	c := Command{}
+	if err := json.Unmarshal(data, &c); err != nil {
+		return err
+	}
+
+	_this.Lock()
+	if _this.closed {
+		_this.Unlock()
+		return io.ErrClosedPipe
+	}
+	_this.commands = append(_this.commands, c)
+	handler := _this.handlers[c.Method]
+	_this.Unlock()
+
+	switch c.Method {
+	case "Runtime.enable":
+		if c.SessionID != "" {
+			_this.createContext(c.SessionID, "")
+		}
+	case "Runtime.callFunctionOn":
+		_this.settle(c.Params)
+		if h, ok := _this.readBlob(c.Params); ok {
+			handler = h
+		}
+	}
+
+	// Like a real browser, a slow command does not hold up the caller or other commands
+	go _this.reply(c, handler)
+
+	return nil
+}
+
+func (_this *Peer) reply(c Command, handler Handler) {
+
+	var result interface{} = map[string]interface{}{}
+	var err error
+
+	if handler != nil {
+		if result, err = handler(c.Params); result == nil {
+			result = map[string]interface{}{}
+		}
+	}
+
+	reply := map[string]interface{}{"id": c.ID}
+	if c.SessionID != "" {
+		reply["sessionId"] = c.SessionID
+	}
+
+	if err != nil {
+		perr := &proton.ProtocolError{}
+		if !errors.As(err, &perr) {
+			perr = &proton.ProtocolError{Code: proton.ErrCodeServerError, Message: err.Error()}
+		}
+		reply["error"] = perr
+	} else {
+		reply["result"] = result
+	}
+
+	b, _ := json.Marshal(reply)
+
+	_this.push(b)
+}
+
+// Receive returns the next reply or event for the browser.
+func (_this *Peer) Receive() ([]byte, error) {
+	_this.Lock()
+	defer _this.Unlock()
+
+	for len(_this.outbox) == 0 && !_this.closed {
+		_this.cond.Wait()
+	}
+
+	if _this.closed {
+		return nil, io.EOF
+	}
+
+	b := _this.outbox[0]
+	_this.outbox = _this.outbox[1:]
+
+	return b, nil
+}
+
+// Close disconnects the peer, as if the browser went away.
+func (_this *Peer) Close() error {
+	_this.Lock()
+	defer _this.Unlock()
+
+	if !_this.closed {
+		_this.closed = true
+		_this.cond.Broadcast()
+
+		for key, pending := range _this.bindings {
+			pending.done <- bindingResult{err: io.EOF}
+			delete(_this.bindings, key)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,166 @@
+package proton
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// Directions of a recorded frame.
+const (
+	FrameSent     = "send"
+	FrameReceived = "receive"
+)
+
+// Frame is one line of a protocol recording written through Config.Recorder.
+type Frame struct {
+	Time      time.Time       `json:"time"`
+	Direction string          `json:"direction"`
+	SessionID string          `json:"sessionId,omitempty"`
+	Data      json.RawMessage `json:"frame"`
+}
+
+// recordingTransport writes every frame going through a transport as JSON lines.
+type recordingTransport struct {
+	Transport
+	sync.Mutex
+	w io.Writer
+}
+
+func (_this *recordingTransport) record(direction string, data []byte) {
+
+	m := struct {
+		SessionID string `json:"sessionId"`
+	}{}
+	json.Unmarshal(data, &m)
+
+	f := Frame{Time: time.Now(), Direction: direction, SessionID: m.SessionID, Data: data}
+
+	if !json.Valid(data) {
+		// Keep the line valid JSON even for a broken frame
+		f.Data, _ = json.Marshal(string(data))
+	}
+
+	b, err := json.Marshal(f)
+	if err != nil {
+		return
+	}
+
+	_this.Lock()
+	_this.w.Write(append(b, '\n'))
+	_this.Unlock()
+}
+
+func (_this *recordingTransport) Send(data []byte) error {
+	_this.record(FrameSent, data)
+	return _this.Transport.Send(data)
+}
+
+func (_this *recordingTransport) Receive() ([]byte, error) {
+
+	data, err := _this.Transport.Receive()
+	if err == nil {
+		_this.record(FrameReceived, data)
+	}
+
+	return data, err
+}
+
+// ReplayTransport feeds a recording made with Config.Recorder back into a Browser.
+// Received frames are delivered in their recorded order, each one only after the
+// browser sent as many frames as were sent before it in the recording.
+// Once the recording is exhausted the connection is reported as closed.
+type ReplayTransport struct {
+	sync.Mutex
+	cond   *sync.Cond
+	frames []replayFrame
+	next   int
+	sent   int
+	closed bool
+}
+
+type replayFrame struct {
+	data  []byte
+	after int // frames sent before this one in the recording
+}
+
+// NewReplayTransport reads a recording written through Config.Recorder.
+func NewReplayTransport(r io.Reader) (*ReplayTransport, error) {
+
+	t := &ReplayTransport{}
+	t.cond = sync.NewCond(&t.Mutex)
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 64*1024*1024)
+
+	sent := 0
+
+	for scanner.Scan() {
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		f := Frame{}
+		if err := json.Unmarshal(scanner.Bytes(), &f); err != nil {
+			return nil, err
+		}
+
+		if f.Direction == FrameSent {
+			sent++
+		} else {
+			t.frames = append(t.frames, replayFrame{data: f.Data, after: sent})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return t, nil
+}
+
+// Send counts the frame as sent, its content is not checked against the recording.
+func (_this *ReplayTransport) Send(data []byte) error {
+	_this.Lock()
+	defer _this.Unlock()
+
+	if _this.closed {
+		return io.ErrClosedPipe
+	}
+
+	_this.sent++
+	_this.cond.Broadcast()
+
+	return nil
+}
+
+// Receive returns the next recorded frame once the frames sent before it were replayed.
+func (_this *ReplayTransport) Receive() ([]byte, error) {
+	_this.Lock()
+	defer _this.Unlock()
+
+	for !_this.closed && _this.next < len(_this.frames) {
+
+		if f := _this.frames[_this.next]; _this.sent >= f.after {
+			_this.next++
+			return f.data, nil
+		}
+
+		_this.cond.Wait()
+	}
+
+	return nil, io.EOF
+}
+
+func (_this *ReplayTransport) Close() error {
+	_this.Lock()
+	defer _this.Unlock()
+
+	_this.closed = true
+	_this.cond.Broadcast()
+
+	return nil
+}
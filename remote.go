@@ -0,0 +1,249 @@
+package proton
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+)
+
+// DefaultObjectGroup is the object group of the remote objects returned by Page.EvalObject.
+const DefaultObjectGroup = "proton"
+
+var objectGroups int32
+
+var errNotObject = errors.New("remote value is not an object")
+
+// RemoteObject is a handle on a JS value kept in the page, such as a DOM node, a function
+// or any object JSON cannot carry. Objects stay alive in the page until they are released,
+// on their own with Release or along with their object group. Primitive values are
+// held by the handle itself and need no release.
+type RemoteObject struct {
+	page  *Page
+	group string
+	obj   remoteObject
+}
+
+// remoteObject is a Runtime.RemoteObject.
+type remoteObject struct {
+	Type        string          `json:"type"`
+	Subtype     string          `json:"subtype"`
+	ClassName   string          `json:"className"`
+	Description string          `json:"description"`
+	Value       json.RawMessage `json:"value"`
+	ObjectID    string          `json:"objectId"`
+}
+
+// ObjectGroup groups remote objects so they are released together.
+type ObjectGroup struct {
+	page *Page
+	name string
+}
+
+// newRemoteObject makes a handle from a result of a command returning by reference.
+// Objects arrive whole, primitives as their bare value.
+func newRemoteObject(page *Page, group string, raw json.RawMessage) *RemoteObject {
+
+	o := &RemoteObject{page: page, group: group}
+
+	if len(raw) > 0 && raw[0] == '{' {
+		json.Unmarshal(raw, &o.obj)
+	} else {
+		o.obj.Value = raw
+	}
+
+	return o
+}
+
+// NewObjectGroup returns a new object group with a unique name.
+func (_this *Page) NewObjectGroup() *ObjectGroup {
+	return _this.ObjectGroup(fmt.Sprintf("proton-%d", atomic.AddInt32(&objectGroups, 1)))
+}
+
+// ObjectGroup returns the object group with the given name.
+func (_this *Page) ObjectGroup(name string) *ObjectGroup {
+	return &ObjectGroup{page: _this, name: name}
+}
+
+// EvalObject evaluates the JS expression and returns a handle on its result in the
+// DefaultObjectGroup, awaiting the result if it is a promise.
+func (_this *Page) EvalObject(js string) (*RemoteObject, error) {
+	return _this.ObjectGroup(DefaultObjectGroup).EvalObject(js)
+}
+
+// Name returns the name of the group.
+func (_this *ObjectGroup) Name() string {
+	return _this.name
+}
+
+// EvalObject is like Page.EvalObject, adding the result to this group.
+func (_this *ObjectGroup) EvalObject(js string) (*RemoteObject, error) {
+	return _this.EvalObjectContext(context.Background(), js)
+}
+
+// EvalObjectContext is like EvalObject, but stops waiting when the context is done.
+func (_this *ObjectGroup) EvalObjectContext(ctx context.Context, js string) (*RemoteObject, error) {
+
+	awaitPromise := true
+
+	res, err := _this.page.RuntimeEvaluate(ctx, RuntimeEvaluateParameters{Expression: js, AwaitPromise: &awaitPromise, ObjectGroup: &_this.name})
+	if err != nil {
+		return nil, err
+	}
+
+	return newRemoteObject(_this.page, _this.name, res), nil
+}
+
+// Release releases every object of the group, their handles can no longer be used.
+func (_this *ObjectGroup) Release() error {
+
+	_, err := _this.page.send("Runtime.releaseObjectGroup", h{"objectGroup": _this.name})
+
+	return err
+}
+
+// ObjectID returns the protocol identifier of the object, empty for a primitive value.
+func (_this *RemoteObject) ObjectID() string {
+	return _this.obj.ObjectID
+}
+
+// Type returns the JS type of the object, e.g. "object" or "function". It is empty
+// for primitive values, use Value to read them.
+func (_this *RemoteObject) Type() string {
+	return _this.obj.Type
+}
+
+// Subtype returns the protocol subtype of an object, e.g. "node", "array" or "null".
+func (_this *RemoteObject) Subtype() string {
+	return _this.obj.Subtype
+}
+
+// ClassName returns the constructor name of an object, e.g. "HTMLDivElement".
+func (_this *RemoteObject) ClassName() string {
+	return _this.obj.ClassName
+}
+
+// Description returns a string representation of the object.
+func (_this *RemoteObject) Description() string {
+	return _this.obj.Description
+}
+
+// Group returns the object group the object belongs to.
+func (_this *RemoteObject) Group() string {
+	return _this.group
+}
+
+// argument passes the object to Runtime.callFunctionOn.
+func (_this *RemoteObject) argument() RuntimeCallArgument {
+
+	if _this.obj.ObjectID != "" {
+		return RuntimeCallArgument{ObjectId: &_this.obj.ObjectID}
+	}
+
+	// An empty argument is undefined
+	return RuntimeCallArgument{Value: _this.obj.Value}
+}
+
+// on calls fn with the object as this, it fails for primitive values.
+func (_this *RemoteObject) on(ctx context.Context, fn string, params h, args []interface{}) (json.RawMessage, error) {
+
+	if _this.obj.ObjectID == "" {
+		return nil, errNotObject
+	}
+
+	params["objectId"] = _this.obj.ObjectID
+
+	return _this.page.callFunction(ctx, fn, params, args)
+}
+
+// Value returns the value of the object encoded as JSON, like Eval would.
+func (_this *RemoteObject) Value() Value {
+
+	if _this.obj.ObjectID == "" {
+		return value{raw: _this.obj.Value}
+	}
+
+	return _this.Call("function() { return this; }")
+}
+
+// Call calls the JS function fn with the object as this and returns its result by value,
+// awaiting it if it is a promise. Arguments are passed as with Page.Call.
+func (_this *RemoteObject) Call(fn string, args ...interface{}) Value {
+
+	v, err := _this.on(context.Background(), fn, h{"returnByValue": true}, args)
+
+	return value{err: err, raw: v}
+}
+
+// CallObject is like Call, returning a handle on the result in the group of the object.
+func (_this *RemoteObject) CallObject(fn string, args ...interface{}) (*RemoteObject, error) {
+
+	res, err := _this.on(context.Background(), fn, h{"objectGroup": _this.group}, args)
+	if err != nil {
+		return nil, err
+	}
+
+	return newRemoteObject(_this.page, _this.group, res), nil
+}
+
+// Get returns a handle on the property prop of the object.
+func (_this *RemoteObject) Get(prop string) (*RemoteObject, error) {
+	return _this.CallObject("function(prop) { return this[prop]; }", prop)
+}
+
+// Properties returns handles on the own properties of the object, keyed by name.
+func (_this *RemoteObject) Properties() (map[string]*RemoteObject, error) {
+
+	if _this.obj.ObjectID == "" {
+		return nil, errNotObject
+	}
+
+	res, err := _this.page.send("Runtime.getProperties", h{"objectId": _this.obj.ObjectID, "ownProperties": true})
+	if err != nil {
+		return nil, err
+	}
+
+	props := struct {
+		Result []struct {
+			Name  string          `json:"name"`
+			Value json.RawMessage `json:"value"`
+		} `json:"result"`
+	}{}
+	if err := json.Unmarshal(res, &props); err != nil {
+		return nil, err
+	}
+
+	properties := map[string]*RemoteObject{}
+
+	for _, p := range props.Result {
+
+		if p.Value == nil {
+			// Accessor property, it has a getter instead of a value
+			continue
+		}
+
+		// Properties are always described as remote objects, they belong to the group of their owner
+		o := &RemoteObject{page: _this.page, group: _this.group}
+		json.Unmarshal(p.Value, &o.obj)
+		if o.obj.ObjectID == "" {
+			o.obj = remoteObject{Value: o.obj.Value}
+		}
+
+		properties[p.Name] = o
+	}
+
+	return properties, nil
+}
+
+// Release releases the object in the page, the handle can no longer be used.
+func (_this *RemoteObject) Release() error {
+
+	if _this.obj.ObjectID == "" {
+		return nil
+	}
+
+	_, err := _this.page.send("Runtime.releaseObject", h{"objectId": _this.obj.ObjectID})
+
+	return err
+}
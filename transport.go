@@ -0,0 +1,131 @@
+package proton
+
+import (
+	"bufio"
+	"os"
+	"os/exec"
+	"sync"
+
+	"golang.org/x/net/websocket"
+)
+
+// outgoingQueueSize is the number of frames buffered for the writer goroutine.
+const outgoingQueueSize = 64
+
+// Transport carries DevTools Protocol messages between proton and the browser.
+// Besides the websocket and pipe transports used by Run, Connect and ConnectHTTP,
+// any implementation can be passed to Browser.ConnectTransport, e.g. the fake
+// peer in the protontest package.
+type Transport interface {
+	// Send writes one JSON encoded message. It is never called concurrently.
+	Send(data []byte) error
+	// Receive blocks until the next JSON encoded message arrives.
+	Receive() ([]byte, error)
+	// Close releases the connection, making pending and future calls fail.
+	Close() error
+}
+
+// wsTransport talks to the browser through the --remote-debugging-port websocket.
+type wsTransport struct {
+	ws *websocket.Conn
+}
+
+func dialWebsocket(wsURL string) (*wsTransport, error) {
+
+	ws, err := websocket.Dial(wsURL, "", "http://127.0.0.1")
+	if err != nil {
+		return nil, err
+	}
+
+	return &wsTransport{ws: ws}, nil
+}
+
+func (_this *wsTransport) Send(data []byte) error {
+	return websocket.Message.Send(_this.ws, string(data))
+}
+
+func (_this *wsTransport) Receive() ([]byte, error) {
+	var data []byte
+	err := websocket.Message.Receive(_this.ws, &data)
+	return data, err
+}
+
+func (_this *wsTransport) Close() error {
+	return _this.ws.Close()
+}
+
+// pipeTransport talks to the browser through --remote-debugging-pipe: the browser
+// reads null-terminated messages from file descriptor 3 and writes them to descriptor 4.
+type pipeTransport struct {
+	sync.Mutex
+	w *os.File
+	r *os.File
+	b *bufio.Reader
+}
+
+func newPipeTransport(w *os.File, r *os.File) *pipeTransport {
+	return &pipeTransport{w: w, r: r, b: bufio.NewReader(r)}
+}
+
+// startPipe starts cmd with the read end of one pipe as file descriptor 3 and the write
+// end of another as descriptor 4, and returns the transport over the other ends.
+func startPipe(cmd *exec.Cmd) (*pipeTransport, error) {
+
+	browserIn, protonOut, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+
+	protonIn, browserOut, err := os.Pipe()
+	if err != nil {
+		browserIn.Close()
+		protonOut.Close()
+		return nil, err
+	}
+
+	cmd.ExtraFiles = []*os.File{browserIn, browserOut}
+
+	err = cmd.Start()
+
+	// The browser holds its own copies now
+	browserIn.Close()
+	browserOut.Close()
+
+	if err != nil {
+		protonOut.Close()
+		protonIn.Close()
+		return nil, err
+	}
+
+	return newPipeTransport(protonOut, protonIn), nil
+}
+
+func (_this *pipeTransport) Send(data []byte) error {
+	_this.Lock()
+	defer _this.Unlock()
+
+	_, err := _this.w.Write(append(data, 0))
+	return err
+}
+
+func (_this *pipeTransport) Receive() ([]byte, error) {
+
+	data, err := _this.b.ReadBytes(0)
+	if err != nil {
+		return nil, err
+	}
+
+	return data[:len(data)-1], nil
+}
+
+func (_this *pipeTransport) Close() error {
+
+	werr := _this.w.Close()
+	rerr := _this.r.Close()
+
+	if werr != nil {
+		return werr
+	}
+
+	return rerr
+}
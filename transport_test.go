@@ -0,0 +1,97 @@
+package proton
+
+import (
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"testing"
+)
+
+func TestPipeTransport(t *testing.T) {
+
+	browserIn, protonOut, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	protonIn, browserOut, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer browserIn.Close()
+	defer browserOut.Close()
+
+	conn := newPipeTransport(protonOut, protonIn)
+	defer conn.Close()
+
+	if err := conn.Send([]byte(`{"id":1}`)); err != nil {
+		t.Fatal(err)
+	}
+	sent := make([]byte, 9)
+	if _, err := io.ReadFull(browserIn, sent); err != nil || string(sent) != "{\"id\":1}\x00" {
+		t.Fatalf("%q %v", sent, err)
+	}
+
+	// Two messages in one write, the second one split across writes
+	go func() {
+		browserOut.Write([]byte("{\"id\":1}\x00{\"id\""))
+		browserOut.Write([]byte(":2}\x00"))
+	}()
+
+	for _, want := range []string{`{"id":1}`, `{"id":2}`} {
+		if data, err := conn.Receive(); err != nil || string(data) != want {
+			t.Fatal(string(data), err)
+		}
+	}
+
+	browserOut.Close()
+	if _, err := conn.Receive(); err != io.EOF {
+		t.Fatal(err)
+	}
+}
+
+// TestPipeBrowser is the browser started by TestStartPipe, it echoes what it reads
+// from file descriptor 3 to descriptor 4.
+func TestPipeBrowser(t *testing.T) {
+
+	if os.Getenv("PROTON_PIPE_BROWSER") != "1" {
+		t.Skip("started by TestStartPipe")
+	}
+
+	io.Copy(os.NewFile(4, "out"), os.NewFile(3, "in"))
+	os.Exit(0)
+}
+
+func TestStartPipe(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("no remote debugging pipe on windows")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestPipeBrowser$")
+	cmd.Env = append(os.Environ(), "PROTON_PIPE_BROWSER=1")
+
+	conn, err := startPipe(cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, msg := range []string{`{"id":1,"method":"Browser.getVersion"}`, `{"id":2}`} {
+		if err := conn.Send([]byte(msg)); err != nil {
+			t.Fatal(err)
+		}
+		if data, err := conn.Receive(); err != nil || string(data) != msg {
+			t.Fatal(string(data), err)
+		}
+	}
+
+	// The browser sees the end of its input and exits, closing its output
+	conn.w.Close()
+	if _, err := conn.Receive(); err != io.EOF {
+		t.Fatal(err)
+	}
+	if err := cmd.Wait(); err != nil {
+		t.Fatal(err)
+	}
+	conn.r.Close()
+}
@@ -43,9 +43,7 @@ type targetMessageTemplate struct {
 			Value interface{} `json:"value"`
 		} `json:"args"`
 	} `json:"params"`
-	Error struct {
-		Message string `json:"message"`
-	} `json:"error"`
+	Error  *ProtocolError  `json:"error"`
 	Result json.RawMessage `json:"result"`
 }
 
@@ -59,11 +57,7 @@ type targetMessage struct {
 			Value       json.RawMessage `json:"value"`
 			ObjectID    string          `json:"objectId"`
 		} `json:"result"`
-		Exception struct {
-			Exception struct {
-				Value json.RawMessage `json:"value"`
-			} `json:"exception"`
-		} `json:"exceptionDetails"`
+		Exception *JSException `json:"exceptionDetails"`
 	} `json:"result"`
 }
 
@@ -152,6 +146,14 @@ type RuntimeEvaluateParameters struct {
 	DisableBreaks         *bool   `json:"disableBreaks"`
 }
 
+// RuntimeCallArgument is an argument of Runtime.callFunctionOn, either a JSON value,
+// a value JSON cannot represent (NaN, Infinity, -0, bigint) or a remote object.
+type RuntimeCallArgument struct {
+	Value               json.RawMessage `json:"value,omitempty"`
+	UnserializableValue *string         `json:"unserializableValue,omitempty"`
+	ObjectId            *string         `json:"objectId,omitempty"`
+}
+
 type TransitionType string
 
 const (
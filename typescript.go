@@ -0,0 +1,132 @@
+package proton
+
+import (
+	"os"
+	"reflect"
+
+	"github.com/leandroveronezi/proton/internal/tsgen"
+)
+
+// tsType describes a reflect.Type for the declarations of internal/tsgen.
+type tsType struct {
+	reflect.Type
+}
+
+func (_this tsType) Kind() tsgen.Kind {
+
+	switch _this.Type.Kind() {
+	case reflect.Bool:
+		return tsgen.KindBool
+	case reflect.Uint8:
+		return tsgen.KindByte
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64:
+		return tsgen.KindNumber
+	case reflect.String:
+		return tsgen.KindString
+	case reflect.Ptr:
+		return tsgen.KindPointer
+	case reflect.Slice:
+		return tsgen.KindSlice
+	case reflect.Array:
+		return tsgen.KindArray
+	case reflect.Map:
+		return tsgen.KindMap
+	case reflect.Struct:
+		return tsgen.KindStruct
+	case reflect.Func:
+		return tsgen.KindFunc
+	case reflect.Chan:
+		return tsgen.KindChan
+	}
+
+	return tsgen.KindOther
+}
+
+func (_this tsType) Named() (string, string) {
+	return _this.PkgPath(), _this.Name()
+}
+
+func (_this tsType) ID() interface{} {
+	return _this.Type
+}
+
+func (_this tsType) HasMethod(name string, pointer bool) bool {
+
+	if _, ok := _this.MethodByName(name); ok {
+		return true
+	}
+
+	if !pointer || _this.Type.Kind() == reflect.Interface {
+		return false
+	}
+
+	_, ok := reflect.PtrTo(_this.Type).MethodByName(name)
+
+	return ok
+}
+
+func (_this tsType) Elem() tsgen.Type {
+	return tsType{_this.Type.Elem()}
+}
+
+func (_this tsType) Recv() bool {
+	return _this.ChanDir()&reflect.RecvDir != 0
+}
+
+func (_this tsType) Fields() []tsgen.StructField {
+
+	fields := make([]tsgen.StructField, _this.NumField())
+
+	for i := range fields {
+		f := _this.Field(i)
+		fields[i] = tsgen.StructField{Name: f.Name, Tag: f.Tag.Get("json"), Embedded: f.Anonymous, Exported: f.PkgPath == "", Type: tsType{f.Type}}
+	}
+
+	return fields
+}
+
+func (_this tsType) Params() []tsgen.Type {
+
+	params := make([]tsgen.Type, _this.NumIn())
+	for i := range params {
+		params[i] = tsType{_this.In(i)}
+	}
+
+	return params
+}
+
+func (_this tsType) Results() []tsgen.Type {
+
+	results := make([]tsgen.Type, _this.NumOut())
+	for i := range results {
+		results[i] = tsType{_this.Out(i)}
+	}
+
+	return results
+}
+
+func (_this tsType) Variadic() bool {
+	return _this.IsVariadic()
+}
+
+// TypeScriptDefinitions returns a .d.ts declaration of the functions bound to the page,
+// adding them to the Window interface along with interfaces for the Go structs they use.
+func (_this *Page) TypeScriptDefinitions() string {
+
+	_this.Lock()
+	defer _this.Unlock()
+
+	bindings := map[string]tsgen.Type{}
+	for name, t := range _this.signatures {
+		bindings[name] = tsType{t}
+	}
+
+	return tsgen.Declare(bindings).String()
+}
+
+// WriteTypeScriptDefinitions writes the output of TypeScriptDefinitions to a file.
+func (_this *Page) WriteTypeScriptDefinitions(filename string) error {
+	return os.WriteFile(filename, []byte(_this.TypeScriptDefinitions()), 0644)
+}